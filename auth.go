@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AuthType 认证方式
+type AuthType string
+
+const (
+	AuthBasic     AuthType = "basic"     // 用户名/邮箱 + 密码或API Token
+	AuthBearer    AuthType = "bearer"    // Data Center 个人访问令牌（PAT）
+	AuthAnonymous AuthType = "anonymous" // 匿名访问，仅能读取公开空间
+)
+
+// AuthStrategy 为请求添加认证信息
+type AuthStrategy interface {
+	Type() AuthType
+	Apply(req *http.Request)
+}
+
+// basicAuth HTTP Basic 认证
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a basicAuth) Type() AuthType { return AuthBasic }
+
+func (a basicAuth) Apply(req *http.Request) {
+	req.SetBasicAuth(a.username, a.password)
+}
+
+// bearerAuth Bearer 令牌认证
+type bearerAuth struct {
+	token string
+}
+
+func (a bearerAuth) Type() AuthType { return AuthBearer }
+
+func (a bearerAuth) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+}
+
+// anonymousAuth 不发送认证信息
+type anonymousAuth struct{}
+
+func (anonymousAuth) Type() AuthType { return AuthAnonymous }
+
+func (anonymousAuth) Apply(req *http.Request) {}
+
+// ParseAuthType 解析认证方式名称，空字符串表示自动推断
+func ParseAuthType(value string) (AuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "", nil
+	case "basic":
+		return AuthBasic, nil
+	case "bearer", "pat", "token":
+		return AuthBearer, nil
+	case "anonymous", "none":
+		return AuthAnonymous, nil
+	}
+	return "", fmt.Errorf("不支持的认证方式: %s（可选 basic、bearer、anonymous）", value)
+}
+
+// inferAuthType 根据提供的凭据推断认证方式：只有令牌时使用 Bearer，否则使用 Basic
+// 匿名访问必须显式指定，避免漏填凭据时被当作匿名请求而得到难以理解的 401/404 错误
+func inferAuthType(username, token string) AuthType {
+	if username == "" && token != "" {
+		return AuthBearer
+	}
+	return AuthBasic
+}
+
+// NewAuthStrategy 创建认证策略，authType 为空时根据凭据自动推断
+func NewAuthStrategy(authType AuthType, username, token string) AuthStrategy {
+	if authType == "" {
+		authType = inferAuthType(username, token)
+	}
+	switch authType {
+	case AuthBearer:
+		return bearerAuth{token: token}
+	case AuthAnonymous:
+		return anonymousAuth{}
+	}
+	return basicAuth{username: username, password: token}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidateCredentialsAuthTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		authType string
+		username string
+		token    string
+		wantAuth string // 期望的 Authorization 头，校验失败时忽略
+		wantErr  bool
+	}{
+		{"basic inferred", "", "user@example.com", "token", "Basic dXNlckBleGFtcGxlLmNvbTp0b2tlbg==", false},
+		{"bearer inferred from token only", "", "", "pat", "Bearer pat", false},
+		{"no credentials is an error", "", "", "", "", true},
+		{"username without token is an error", "", "user@example.com", "", "", true},
+		{"explicit anonymous", "anonymous", "", "", "", false},
+		{"explicit bearer ignores username", "bearer", "user", "pat", "Bearer pat", false},
+		{"explicit bearer requires token", "pat", "", "", "", true},
+		{"explicit basic requires username", "basic", "", "token", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authType, err := ParseAuthType(tt.authType)
+			if err != nil {
+				t.Fatalf("ParseAuthType(%q) error = %v", tt.authType, err)
+			}
+			client := NewConfluenceClientWithCredentials("https://confluence.example.com", tt.username, tt.token)
+			client.AuthType = authType
+
+			err = client.ValidateCredentials()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, client.BaseURL, nil)
+			client.authStrategy().Apply(req)
+			if got := req.Header.Get("Authorization"); got != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestParseAuthTypeRejectsUnknown(t *testing.T) {
+	if _, err := ParseAuthType("kerberos"); err == nil {
+		t.Error("ParseAuthType(\"kerberos\") expected error")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+)
+
+// codeLanguages Confluence代码宏语言名到Markdown代码块信息字符串的映射，
+// 未列出的语言名原样使用
+var codeLanguages = map[string]string{
+	"actionscript3": "actionscript",
+	"c#":            "csharp",
+	"c++":           "cpp",
+	"coldfusion":    "cfm",
+	"erl":           "erlang",
+	"javafx":        "java",
+	"js":            "javascript",
+	"py":            "python",
+	"rb":            "ruby",
+	"sh":            "bash",
+	"shell":         "bash",
+	"vb":            "vbnet",
+	"yml":           "yaml",
+	"text":          "",
+	"none":          "",
+}
+
+// renderCodeMacro 渲染 code 和 noformat 宏
+// title 参数作为代码块标题输出；linenumbers、collapse 等参数只影响页面展示，Markdown中忽略
+func (m *markdownConverter) renderCodeMacro(n *storageNode, params map[string]string) string {
+	body := n.child("ac:plain-text-body")
+	if body == nil {
+		return ""
+	}
+
+	language := ""
+	if n.macroName() == "code" {
+		language = codeLanguage(params["language"])
+	}
+
+	code := fencedCode(language, body.textContent())
+	if title := params["title"]; title != "" {
+		return "**" + title + "**\n\n" + code
+	}
+	return code
+}
+
+// codeLanguage 将Confluence语言名转换为代码块信息字符串
+func codeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if mapped, ok := codeLanguages[language]; ok {
+		return mapped
+	}
+	return language
+}
+
+// fencedCode 生成围栏代码块，围栏长度超过代码中最长的连续反引号
+func fencedCode(language, code string) string {
+	code = strings.Trim(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+
+	fence := strings.Repeat("`", max(3, longestBacktickRun(code)+1))
+
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// inlineCode 生成行内代码，反引号串长度超过代码中最长的连续反引号，
+// 代码以反引号开头或结尾时在内侧补空格；前后空白保留在代码之外
+func inlineCode(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:len(text)-len(strings.TrimLeft(text, " \t\n"))]
+	trailing := text[len(strings.TrimRight(text, " \t\n")):]
+
+	marker := strings.Repeat("`", longestBacktickRun(trimmed)+1)
+	if strings.HasPrefix(trimmed, "`") || strings.HasSuffix(trimmed, "`") {
+		trimmed = " " + trimmed + " "
+	}
+	return leading + marker + trimmed + marker + trailing
+}
+
+// longestBacktickRun 返回文本中最长的连续反引号数量
+func longestBacktickRun(text string) int {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
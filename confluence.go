@@ -2,12 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,7 +19,11 @@ type ConfluenceClient struct {
 	BaseURL    string
 	Email      string
 	APIToken   string
+	AuthType   AuthType // 认证方式，为空时根据凭据自动推断
 	HTTPClient *http.Client
+	Retry      RetryPolicy    // 限流和临时故障时的重试策略
+	Deployment DeploymentType // 部署类型，由 DetectDeployment 识别
+	Jira       *JiraClient    // 可选，用于展开Jira宏中的问题摘要和状态
 }
 
 // NewConfluenceClient 创建新的 Confluence 客户端
@@ -27,6 +32,7 @@ func NewConfluenceClient() *ConfluenceClient {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retry: DefaultRetryPolicy(),
 	}
 }
 
@@ -39,6 +45,7 @@ func NewConfluenceClientWithCredentials(baseURL, email, apiToken string) *Conflu
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retry: DefaultRetryPolicy(),
 	}
 }
 
@@ -54,39 +61,48 @@ func (c *ConfluenceClient) ValidateCredentials() error {
 	if c.BaseURL == "" {
 		return fmt.Errorf("缺少 Confluence Base URL")
 	}
-	if c.Email == "" {
-		return fmt.Errorf("缺少用户邮箱")
-	}
-	if c.APIToken == "" {
-		return fmt.Errorf("缺少 API Token")
+	switch c.authStrategy().Type() {
+	case AuthBasic:
+		if c.Email == "" {
+			return fmt.Errorf("缺少用户邮箱")
+		}
+		if c.APIToken == "" {
+			return fmt.Errorf("缺少 API Token")
+		}
+	case AuthBearer:
+		if c.APIToken == "" {
+			return fmt.Errorf("缺少个人访问令牌（PAT）")
+		}
 	}
 	return nil
 }
 
+// authStrategy 返回当前凭据对应的认证策略
+func (c *ConfluenceClient) authStrategy() AuthStrategy {
+	return NewAuthStrategy(c.AuthType, c.Email, c.APIToken)
+}
+
 // makeRequest 发送 HTTP 请求
-func (c *ConfluenceClient) makeRequest(method, endpoint string, body interface{}) (*http.Response, error) {
-	var reqBody io.Reader
+func (c *ConfluenceClient) makeRequest(ctx context.Context, method, endpoint string, body interface{}) (*http.Response, error) {
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("序列化请求体失败: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonData)
 	}
 
 	url := fmt.Sprintf("%s/rest/api%s", c.BaseURL, endpoint)
-	req, err := http.NewRequest(method, url, reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	req.SetBasicAuth(c.Email, c.APIToken)
-	req.Header.Set("Accept", "application/json")
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+	newRequest := func(ctx context.Context) (*http.Request, error) {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(jsonData)
+		}
+		return c.newRequest(ctx, method, url, reqBody)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.Retry.do(ctx, c.HTTPClient, isIdempotentRequest(method, endpoint), newRequest)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -94,12 +110,27 @@ func (c *ConfluenceClient) makeRequest(method, endpoint string, body interface{}
 	if resp.StatusCode >= 400 {
 		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API 请求失败 (状态码: %d): %s", resp.StatusCode, string(bodyBytes))
+		return nil, newAPIError(resp, bodyBytes)
 	}
 
 	return resp, nil
 }
 
+// newRequest 创建带认证信息的请求
+func (c *ConfluenceClient) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	c.authStrategy().Apply(req)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
 // PageInfo 页面信息结构
 type PageInfo struct {
 	ID     string `json:"id"`
@@ -221,9 +252,9 @@ type SearchResult struct {
 }
 
 // GetPageComments 获取页面评论
-func (c *ConfluenceClient) GetPageComments(pageID string) ([]CommentInfo, error) {
+func (c *ConfluenceClient) GetPageComments(ctx context.Context, pageID string) ([]CommentInfo, error) {
 	endpoint := fmt.Sprintf("/content/%s/child/comment?expand=body.storage,version", pageID)
-	resp, err := c.makeRequest("GET", endpoint, nil)
+	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("获取页面评论失败: %w", err)
 	}
@@ -243,10 +274,9 @@ func (c *ConfluenceClient) GetPageComments(pageID string) ([]CommentInfo, error)
 	return commentsResponse.Results, nil
 }
 
-// GetPage 获取页面信息（包含评论）
-func (c *ConfluenceClient) GetPage(pageID string) (*PageWithCommentsResponse, error) {
-	// 获取页面信息
-	resp, err := c.makeRequest("GET", fmt.Sprintf("/content/%s?expand=body.storage,version,space", pageID), nil)
+// GetPageContent 获取页面信息（不含评论）
+func (c *ConfluenceClient) GetPageContent(ctx context.Context, pageID string) (*PageResponse, error) {
+	resp, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/content/%s?expand=body.storage,version,space", pageID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("获取页面失败: %w", err)
 	}
@@ -257,8 +287,19 @@ func (c *ConfluenceClient) GetPage(pageID string) (*PageWithCommentsResponse, er
 		return nil, fmt.Errorf("解析页面数据失败: %w", err)
 	}
 
+	return &page, nil
+}
+
+// GetPage 获取页面信息（包含评论）
+func (c *ConfluenceClient) GetPage(ctx context.Context, pageID string) (*PageWithCommentsResponse, error) {
+	// 获取页面信息
+	page, err := c.GetPageContent(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取页面评论
-	comments, err := c.GetPageComments(pageID)
+	comments, err := c.GetPageComments(ctx, pageID)
 	if err != nil {
 		// 如果获取评论失败，记录错误但不影响页面获取
 		comments = []CommentInfo{}
@@ -266,7 +307,7 @@ func (c *ConfluenceClient) GetPage(pageID string) (*PageWithCommentsResponse, er
 
 	// 组合页面和评论数据
 	result := &PageWithCommentsResponse{
-		Page:     page,
+		Page:     *page,
 		Comments: comments,
 	}
 
@@ -274,9 +315,9 @@ func (c *ConfluenceClient) GetPage(pageID string) (*PageWithCommentsResponse, er
 }
 
 // GetChildPages 获取子页面列表
-func (c *ConfluenceClient) GetChildPages(pageID string, limit, start int) (*ChildPagesResponse, error) {
+func (c *ConfluenceClient) GetChildPages(ctx context.Context, pageID string, limit, start int) (*ChildPagesResponse, error) {
 	endpoint := fmt.Sprintf("/content/%s/child/page?expand=version,history&limit=%d&start=%d", pageID, limit, start)
-	resp, err := c.makeRequest("GET", endpoint, nil)
+	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("获取子页面失败: %w", err)
 	}
@@ -375,7 +416,7 @@ type SearchResponse struct {
 }
 
 // CreatePage 创建页面
-func (c *ConfluenceClient) CreatePage(title, content, spaceKey, parentID string) (*PageResponse, error) {
+func (c *ConfluenceClient) CreatePage(ctx context.Context, title, content, spaceKey, parentID string) (*PageResponse, error) {
 	req := CreatePageRequest{
 		Type:  "page",
 		Title: title,
@@ -390,7 +431,7 @@ func (c *ConfluenceClient) CreatePage(title, content, spaceKey, parentID string)
 		}{{ID: parentID}}
 	}
 
-	resp, err := c.makeRequest("POST", "/content", req)
+	resp, err := c.makeRequest(ctx, "POST", "/content", req)
 	if err != nil {
 		return nil, fmt.Errorf("创建页面失败: %w", err)
 	}
@@ -404,6 +445,108 @@ func (c *ConfluenceClient) CreatePage(title, content, spaceKey, parentID string)
 	return &page, nil
 }
 
+// UpdatePageRequest 更新页面请求结构
+type UpdatePageRequest struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Version struct {
+		Number  int    `json:"number"`
+		Message string `json:"message,omitempty"`
+	} `json:"version"`
+	Body struct {
+		Storage struct {
+			Value          string `json:"value"`
+			Representation string `json:"representation"`
+		} `json:"storage"`
+	} `json:"body"`
+}
+
+// VersionConflictError 页面版本与预期不一致
+type VersionConflictError struct {
+	Expected int
+	Current  int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("页面在你读取之后已被修改（期望版本: %d，当前版本: %d），请重新获取页面内容后基于最新版本更新", e.Expected, e.Current)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// UpdatePage 更新页面，使用乐观锁：expectedVersion 为读取页面时的版本号（必须大于 0），提交 expectedVersion+1
+// title 或 content 为空时保留当前值
+func (c *ConfluenceClient) UpdatePage(ctx context.Context, pageID, title, content string, expectedVersion int, versionMessage string) (*PageResponse, error) {
+	current, err := c.currentPageForUpdate(ctx, pageID, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.updatePageContent(ctx, current, title, content, versionMessage)
+}
+
+// currentPageForUpdate 读取当前页面并检查版本号，expectedVersion 不大于 0 时拒绝更新，避免覆盖他人的修改
+func (c *ConfluenceClient) currentPageForUpdate(ctx context.Context, pageID string, expectedVersion int) (*PageResponse, error) {
+	if expectedVersion <= 0 {
+		return nil, fmt.Errorf("必须提供读取页面时的版本号（当前为 %d）", expectedVersion)
+	}
+	current, err := c.GetPageContent(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Version.Number != expectedVersion {
+		return nil, &VersionConflictError{Expected: expectedVersion, Current: current.Version.Number}
+	}
+	return current, nil
+}
+
+// updatePageContent 基于已读取的当前页面提交更新，版本号为当前版本+1
+// title 或 content 为空时保留当前值；提交时发生 409 冲突返回 VersionConflictError
+func (c *ConfluenceClient) updatePageContent(ctx context.Context, current *PageResponse, title, content, versionMessage string) (*PageResponse, error) {
+	req := UpdatePageRequest{
+		ID:    current.ID,
+		Type:  current.Type,
+		Title: title,
+	}
+	if req.Type == "" {
+		req.Type = "page"
+	}
+	if req.Title == "" {
+		req.Title = current.Title
+	}
+	if content == "" {
+		content = current.Body.Storage.Value
+	}
+	req.Version.Number = current.Version.Number + 1
+	req.Version.Message = versionMessage
+	req.Body.Storage.Value = content
+	req.Body.Storage.Representation = "storage"
+
+	resp, err := c.makeRequest(ctx, "PUT", fmt.Sprintf("/content/%s", current.ID), req)
+	if err != nil {
+		// 读取版本和提交之间页面被他人修改
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+			latest := current.Version.Number + 1
+			if page, getErr := c.GetPageContent(ctx, current.ID); getErr == nil {
+				latest = page.Version.Number
+			}
+			return nil, &VersionConflictError{Expected: current.Version.Number, Current: latest}
+		}
+		return nil, fmt.Errorf("更新页面失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var page PageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("解析更新页面响应失败: %w", err)
+	}
+
+	return &page, nil
+}
+
 // CreateCommentRequest 创建评论请求结构
 type CreateCommentRequest struct {
 	Type      string `json:"type"`
@@ -420,7 +563,7 @@ type CreateCommentRequest struct {
 }
 
 // CreateComment 创建评论
-func (c *ConfluenceClient) CreateComment(pageID, comment string) (*CommentInfo, error) {
+func (c *ConfluenceClient) CreateComment(ctx context.Context, pageID, comment string) (*CommentInfo, error) {
 	req := CreateCommentRequest{
 		Type: "comment",
 	}
@@ -429,7 +572,7 @@ func (c *ConfluenceClient) CreateComment(pageID, comment string) (*CommentInfo,
 	req.Body.Storage.Value = comment
 	req.Body.Storage.Representation = "storage"
 
-	resp, err := c.makeRequest("POST", "/content", req)
+	resp, err := c.makeRequest(ctx, "POST", "/content", req)
 	if err != nil {
 		return nil, fmt.Errorf("创建评论失败: %w", err)
 	}
@@ -444,7 +587,7 @@ func (c *ConfluenceClient) CreateComment(pageID, comment string) (*CommentInfo,
 }
 
 // SearchPages 搜索页面
-func (c *ConfluenceClient) SearchPages(query, spaceKey string, limit, start int) (*SearchResponse, error) {
+func (c *ConfluenceClient) SearchPages(ctx context.Context, query, spaceKey string, limit, start int) (*SearchResponse, error) {
 	params := url.Values{}
 	params.Set("cql", fmt.Sprintf("text ~ \"%s\" and type = page", query))
 	if spaceKey != "" {
@@ -455,7 +598,7 @@ func (c *ConfluenceClient) SearchPages(query, spaceKey string, limit, start int)
 	params.Set("expand", "version,history,body.storage")
 
 	endpoint := fmt.Sprintf("/content/search?%s", params.Encode())
-	resp, err := c.makeRequest("GET", endpoint, nil)
+	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("搜索页面失败: %w", err)
 	}
@@ -469,6 +612,181 @@ func (c *ConfluenceClient) SearchPages(query, spaceKey string, limit, start int)
 	return &searchResp, nil
 }
 
+// ContentSearchResponse CQL搜索结果（包含页面正文）
+type ContentSearchResponse struct {
+	Results []PageResponse `json:"results"`
+	Start   int            `json:"start"`
+	Limit   int            `json:"limit"`
+	Size    int            `json:"size"`
+}
+
+// SearchContentByCQL 使用CQL搜索内容，结果包含存储格式正文
+func (c *ConfluenceClient) SearchContentByCQL(ctx context.Context, cql string, limit, start int) (*ContentSearchResponse, error) {
+	params := url.Values{}
+	params.Set("cql", cql)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("start", strconv.Itoa(start))
+	params.Set("expand", "body.storage,version,space")
+
+	endpoint := fmt.Sprintf("/content/search?%s", params.Encode())
+	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CQL搜索失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var searchResp ContentSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, fmt.Errorf("解析搜索结果失败: %w", err)
+	}
+
+	return &searchResp, nil
+}
+
+// cqlQuoter 转义CQL字符串中的反斜杠和双引号
+var cqlQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// quoteCQL 将值转为带双引号的CQL字符串字面量，避免值中的引号改变查询含义
+func quoteCQL(value string) string {
+	return `"` + cqlQuoter.Replace(value) + `"`
+}
+
+// FindContentByTitle 按空间和标题查找内容，contentType 为 page 或 blogpost
+// postingDay 仅用于博客文章（格式 yyyy-mm-dd）
+func (c *ConfluenceClient) FindContentByTitle(ctx context.Context, spaceKey, title, contentType, postingDay string) (*PageResponse, error) {
+	params := url.Values{}
+	params.Set("spaceKey", spaceKey)
+	params.Set("title", title)
+	params.Set("type", contentType)
+	if postingDay != "" {
+		params.Set("postingDay", postingDay)
+	}
+	params.Set("expand", "space,version")
+
+	resp, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/content?%s", params.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("查找内容失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var contentResp struct {
+		Results []PageResponse `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&contentResp); err != nil {
+		return nil, fmt.Errorf("解析内容数据失败: %w", err)
+	}
+	if len(contentResp.Results) == 0 {
+		return nil, fmt.Errorf("未找到内容: %s/%s", spaceKey, title)
+	}
+
+	return &contentResp.Results[0], nil
+}
+
+// AttachmentInfo 附件信息结构
+type AttachmentInfo struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Metadata struct {
+		MediaType string `json:"mediaType"`
+	} `json:"metadata"`
+	Extensions struct {
+		MediaType string `json:"mediaType"`
+		FileSize  int64  `json:"fileSize"`
+	} `json:"extensions"`
+	Links struct {
+		Download string `json:"download"`
+	} `json:"_links"`
+}
+
+// GetAttachments 获取页面附件列表
+func (c *ConfluenceClient) GetAttachments(ctx context.Context, pageID string) ([]AttachmentInfo, error) {
+	endpoint := fmt.Sprintf("/content/%s/child/attachment?expand=metadata&limit=200", pageID)
+	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取页面附件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var attachmentsResponse struct {
+		Results []AttachmentInfo `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&attachmentsResponse); err != nil {
+		return nil, fmt.Errorf("解析附件数据失败: %w", err)
+	}
+
+	return attachmentsResponse.Results, nil
+}
+
+// ConvertContentBody 使用Confluence接口将其他格式（如 wiki）的内容转换为存储格式
+func (c *ConfluenceClient) ConvertContentBody(ctx context.Context, value, representation string) (string, error) {
+	req := map[string]string{
+		"value":          value,
+		"representation": representation,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/contentbody/convert/storage", req)
+	if err != nil {
+		return "", fmt.Errorf("转换内容格式失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var converted struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&converted); err != nil {
+		return "", fmt.Errorf("解析转换结果失败: %w", err)
+	}
+
+	return converted.Value, nil
+}
+
+// ToStorageFormat 将 markdown、wiki 或 storage 格式的内容转换为存储格式
+// markdown 在本地转换；wiki 通过Confluence接口转换；storage 只做格式校验
+func (c *ConfluenceClient) ToStorageFormat(ctx context.Context, content, format string) (string, error) {
+	switch format {
+	case "", "storage":
+		if err := validateStorage(content); err != nil {
+			return "", err
+		}
+		return content, nil
+	case "markdown":
+		return markdownToStorage(content), nil
+	case "wiki":
+		return c.ConvertContentBody(ctx, content, "wiki")
+	default:
+		return "", fmt.Errorf("不支持的内容格式: %s（可选 markdown、storage、wiki）", format)
+	}
+}
+
+// UserInfo 用户信息结构
+type UserInfo struct {
+	Type        string `json:"type"`
+	AccountID   string `json:"accountId"`
+	UserKey     string `json:"userKey"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+}
+
+// GetUser 获取用户信息，param 为 accountId（Cloud）、key 或 username（Server/Data Center）
+func (c *ConfluenceClient) GetUser(ctx context.Context, param, value string) (*UserInfo, error) {
+	params := url.Values{}
+	params.Set(param, value)
+
+	resp, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/user?%s", params.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var user UserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("解析用户信息失败: %w", err)
+	}
+
+	return &user, nil
+}
+
 // MarkdownPageResponse Markdown格式的页面响应
 type MarkdownPageResponse struct {
 	Metadata MarkdownMetadata `json:"metadata"`
@@ -485,12 +803,23 @@ type MarkdownMetadata struct {
 	LastUpdated time.Time `json:"last_updated"`
 	UpdatedBy   string    `json:"updated_by"`
 	WebURL      string    `json:"web_url"`
+	// UnsupportedMacros 页面（含评论）中未支持的宏及其出现次数
+	UnsupportedMacros map[string]int `json:"unsupported_macros,omitempty"`
+}
+
+// MarkdownOptions Markdown转换选项
+type MarkdownOptions struct {
+	IncludeAttachments bool // 在末尾追加附件列表
+	ColumnSeparators   bool // 多列布局中以 <!-- column N --> 注释分隔各列
+	ExpandJira         bool // 通过Jira客户端内联Jira宏的问题摘要和状态
+	ExpandIncludes     bool // 内联 include/excerpt-include 引用的页面，展开 children/pagetree 子页面列表
+	IncludeDepth       int  // 嵌入页面的最大深度，0 表示使用默认值
 }
 
 // ConvertPageToMarkdown 将页面内容转换为Markdown格式
-func (c *ConfluenceClient) ConvertPageToMarkdown(pageID string) (*MarkdownPageResponse, error) {
+func (c *ConfluenceClient) ConvertPageToMarkdown(ctx context.Context, pageID string, opts MarkdownOptions) (*MarkdownPageResponse, error) {
 	// 获取页面和评论数据
-	pageWithComments, err := c.GetPage(pageID)
+	pageWithComments, err := c.GetPage(ctx, pageID)
 	if err != nil {
 		return nil, fmt.Errorf("获取页面数据失败: %w", err)
 	}
@@ -511,7 +840,12 @@ func (c *ConfluenceClient) ConvertPageToMarkdown(pageID string) (*MarkdownPageRe
 	}
 
 	// 转换页面内容为Markdown
-	markdownContent := c.convertToMarkdown(pageWithComments)
+	converter := c.newMarkdownConverter(ctx)
+	converter.opts = opts
+	markdownContent := c.convertToMarkdown(converter, pageWithComments)
+	if len(converter.unsupportedMacros) > 0 {
+		metadata.UnsupportedMacros = converter.unsupportedMacros
+	}
 
 	return &MarkdownPageResponse{
 		Metadata: metadata,
@@ -520,8 +854,10 @@ func (c *ConfluenceClient) ConvertPageToMarkdown(pageID string) (*MarkdownPageRe
 }
 
 // convertToMarkdown 将Confluence存储格式转换为Markdown
-func (c *ConfluenceClient) convertToMarkdown(pageWithComments *PageWithCommentsResponse) string {
+// 页面和评论共用一个转换器，用户信息等查询结果在本次请求内缓存
+func (c *ConfluenceClient) convertToMarkdown(converter *markdownConverter, pageWithComments *PageWithCommentsResponse) string {
 	var markdown strings.Builder
+	converter.setPage(&pageWithComments.Page)
 
 	// 添加页面标题和元数据
 	markdown.WriteString(fmt.Sprintf("# %s\n\n", pageWithComments.Page.Title))
@@ -543,10 +879,19 @@ func (c *ConfluenceClient) convertToMarkdown(pageWithComments *PageWithCommentsR
 
 	// 添加页面内容
 	markdown.WriteString("## 页面内容\n\n")
-	pageContent := c.htmlToMarkdown(pageWithComments.Page.Body.Storage.Value)
+	pageContent := converter.convert(pageWithComments.Page.Body.Storage.Value)
 	markdown.WriteString(pageContent)
 	markdown.WriteString("\n\n")
 
+	// 添加附件部分
+	if converter.opts.IncludeAttachments {
+		if section := converter.renderAttachments(pageWithComments.Page.ID); section != "" {
+			markdown.WriteString("## 附件\n\n")
+			markdown.WriteString(section)
+			markdown.WriteString("\n\n")
+		}
+	}
+
 	// 添加评论部分
 	if len(pageWithComments.Comments) > 0 {
 		markdown.WriteString("## 评论\n\n")
@@ -565,7 +910,7 @@ func (c *ConfluenceClient) convertToMarkdown(pageWithComments *PageWithCommentsR
 			markdown.WriteString("\n")
 
 			// 评论内容
-			commentContent := c.htmlToMarkdown(comment.Body.Storage.Value)
+			commentContent := converter.convert(comment.Body.Storage.Value)
 			markdown.WriteString(commentContent)
 			markdown.WriteString("\n\n")
 		}
@@ -573,195 +918,3 @@ func (c *ConfluenceClient) convertToMarkdown(pageWithComments *PageWithCommentsR
 
 	return markdown.String()
 }
-
-// htmlToMarkdown 将Confluence HTML存储格式转换为Markdown
-func (c *ConfluenceClient) htmlToMarkdown(html string) string {
-	// 移除XML声明和根标签
-	content := strings.TrimSpace(html)
-
-	// 移除常见的XML声明
-	if strings.HasPrefix(content, "<?xml") {
-		if idx := strings.Index(content, "?>"); idx != -1 {
-			content = content[idx+2:]
-		}
-	}
-
-	// 基本的HTML到Markdown转换
-	content = c.convertBasicHTML(content)
-	content = c.convertConfluenceSpecific(content)
-	content = c.cleanupMarkdown(content)
-
-	return strings.TrimSpace(content)
-}
-
-// convertBasicHTML 转换基本的HTML标签
-func (c *ConfluenceClient) convertBasicHTML(content string) string {
-	// 标题转换
-	content = regexp.MustCompile(`<h([1-6])(?:[^>]*)>(.*?)</h[1-6]>`).ReplaceAllStringFunc(content, func(match string) string {
-		re := regexp.MustCompile(`<h([1-6])(?:[^>]*)>(.*?)</h[1-6]>`)
-		matches := re.FindStringSubmatch(match)
-		if len(matches) >= 3 {
-			level := matches[1]
-			text := strings.TrimSpace(matches[2])
-			text = c.stripHTMLTags(text)
-			return strings.Repeat("#", parseInt(level)) + " " + text + "\n\n"
-		}
-		return match
-	})
-
-	// 段落转换
-	content = regexp.MustCompile(`<p(?:[^>]*)>(.*?)</p>`).ReplaceAllStringFunc(content, func(match string) string {
-		re := regexp.MustCompile(`<p(?:[^>]*)>(.*?)</p>`)
-		matches := re.FindStringSubmatch(match)
-		if len(matches) >= 2 {
-			text := strings.TrimSpace(matches[1])
-			if text != "" {
-				return text + "\n\n"
-			}
-		}
-		return ""
-	})
-
-	// 粗体转换
-	content = regexp.MustCompile(`<strong(?:[^>]*)>(.*?)</strong>`).ReplaceAllString(content, "**$1**")
-	content = regexp.MustCompile(`<b(?:[^>]*)>(.*?)</b>`).ReplaceAllString(content, "**$1**")
-
-	// 斜体转换
-	content = regexp.MustCompile(`<em(?:[^>]*)>(.*?)</em>`).ReplaceAllString(content, "*$1*")
-	content = regexp.MustCompile(`<i(?:[^>]*)>(.*?)</i>`).ReplaceAllString(content, "*$1*")
-
-	// 代码转换
-	content = regexp.MustCompile(`<code(?:[^>]*)>(.*?)</code>`).ReplaceAllString(content, "`$1`")
-
-	// 链接转换
-	content = regexp.MustCompile(`<a(?:[^>]*)\s+href="([^"]*)"(?:[^>]*)>(.*?)</a>`).ReplaceAllString(content, "[$2]($1)")
-
-	// 换行转换
-	content = regexp.MustCompile(`<br\s*/?>`).ReplaceAllString(content, "  \n")
-
-	// 列表转换
-	content = c.convertLists(content)
-
-	return content
-}
-
-// convertLists 转换列表
-func (c *ConfluenceClient) convertLists(content string) string {
-	// 无序列表
-	content = regexp.MustCompile(`<ul(?:[^>]*)>(.*?)</ul>`).ReplaceAllStringFunc(content, func(match string) string {
-		re := regexp.MustCompile(`<ul(?:[^>]*)>(.*?)</ul>`)
-		matches := re.FindStringSubmatch(match)
-		if len(matches) >= 2 {
-			listContent := matches[1]
-			listContent = regexp.MustCompile(`<li(?:[^>]*)>(.*?)</li>`).ReplaceAllString(listContent, "- $1\n")
-			return "\n" + listContent + "\n"
-		}
-		return match
-	})
-
-	// 有序列表
-	content = regexp.MustCompile(`<ol(?:[^>]*)>(.*?)</ol>`).ReplaceAllStringFunc(content, func(match string) string {
-		re := regexp.MustCompile(`<ol(?:[^>]*)>(.*?)</ol>`)
-		matches := re.FindStringSubmatch(match)
-		if len(matches) >= 2 {
-			listContent := matches[1]
-			counter := 1
-			listContent = regexp.MustCompile(`<li(?:[^>]*)>(.*?)</li>`).ReplaceAllStringFunc(listContent, func(item string) string {
-				re := regexp.MustCompile(`<li(?:[^>]*)>(.*?)</li>`)
-				itemMatches := re.FindStringSubmatch(item)
-				if len(itemMatches) >= 2 {
-					result := fmt.Sprintf("%d. %s\n", counter, itemMatches[1])
-					counter++
-					return result
-				}
-				return item
-			})
-			return "\n" + listContent + "\n"
-		}
-		return match
-	})
-
-	return content
-}
-
-// convertConfluenceSpecific 转换Confluence特定的标签
-func (c *ConfluenceClient) convertConfluenceSpecific(content string) string {
-	// Confluence代码块
-	content = regexp.MustCompile(`<ac:structured-macro[^>]*ac:name="code"[^>]*>(.*?)</ac:structured-macro>`).ReplaceAllStringFunc(content, func(match string) string {
-		// 提取语言参数
-		langRe := regexp.MustCompile(`<ac:parameter[^>]*ac:name="language"[^>]*>(.*?)</ac:parameter>`)
-		langMatches := langRe.FindStringSubmatch(match)
-		language := ""
-		if len(langMatches) >= 2 {
-			language = langMatches[1]
-		}
-
-		// 提取代码内容
-		codeRe := regexp.MustCompile(`<ac:plain-text-body><!\[CDATA\[(.*?)\]\]></ac:plain-text-body>`)
-		codeMatches := codeRe.FindStringSubmatch(match)
-		if len(codeMatches) >= 2 {
-			code := codeMatches[1]
-			return fmt.Sprintf("\n```%s\n%s\n```\n\n", language, code)
-		}
-		return match
-	})
-
-	// Confluence信息框
-	content = regexp.MustCompile(`<ac:structured-macro[^>]*ac:name="info"[^>]*>(.*?)</ac:structured-macro>`).ReplaceAllStringFunc(content, func(match string) string {
-		bodyRe := regexp.MustCompile(`<ac:rich-text-body>(.*?)</ac:rich-text-body>`)
-		bodyMatches := bodyRe.FindStringSubmatch(match)
-		if len(bodyMatches) >= 2 {
-			body := c.stripHTMLTags(bodyMatches[1])
-			return fmt.Sprintf("\n> ℹ️ **信息**: %s\n\n", body)
-		}
-		return match
-	})
-
-	// Confluence警告框
-	content = regexp.MustCompile(`<ac:structured-macro[^>]*ac:name="warning"[^>]*>(.*?)</ac:structured-macro>`).ReplaceAllStringFunc(content, func(match string) string {
-		bodyRe := regexp.MustCompile(`<ac:rich-text-body>(.*?)</ac:rich-text-body>`)
-		bodyMatches := bodyRe.FindStringSubmatch(match)
-		if len(bodyMatches) >= 2 {
-			body := c.stripHTMLTags(bodyMatches[1])
-			return fmt.Sprintf("\n> ⚠️ **警告**: %s\n\n", body)
-		}
-		return match
-	})
-
-	// Confluence表格 (简化处理)
-	content = regexp.MustCompile(`<table[^>]*>(.*?)</table>`).ReplaceAllStringFunc(content, func(match string) string {
-		// 简单的表格转换，实际实现可能需要更复杂的逻辑
-		return "\n[表格内容 - 需要手动格式化]\n\n"
-	})
-
-	return content
-}
-
-// stripHTMLTags 移除HTML标签
-func (c *ConfluenceClient) stripHTMLTags(content string) string {
-	re := regexp.MustCompile(`<[^>]*>`)
-	return re.ReplaceAllString(content, "")
-}
-
-// cleanupMarkdown 清理Markdown格式
-func (c *ConfluenceClient) cleanupMarkdown(content string) string {
-	// 移除多余的空行
-	content = regexp.MustCompile(`\n{3,}`).ReplaceAllString(content, "\n\n")
-
-	// 移除行首行尾的空格
-	lines := strings.Split(content, "\n")
-	for i, line := range lines {
-		lines[i] = strings.TrimSpace(line)
-	}
-	content = strings.Join(lines, "\n")
-
-	return content
-}
-
-// parseInt 辅助函数：字符串转整数
-func parseInt(s string) int {
-	if i, err := strconv.Atoi(s); err == nil {
-		return i
-	}
-	return 1
-}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdatePageRequiresExpectedVersion(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"id":"1","type":"page","title":"T","version":{"number":3}}`))
+	}))
+	defer server.Close()
+	client := NewConfluenceClientWithCredentials(server.URL, "user", "token")
+
+	for _, version := range []int{0, -1} {
+		if _, err := client.UpdatePage(context.Background(), "1", "", "<p>x</p>", version, ""); err == nil {
+			t.Errorf("UpdatePage(version %d) expected error", version)
+		}
+		if _, err := client.PatchPageSection(context.Background(), "1", "A", "append", "<p>x</p>", version, ""); err == nil {
+			t.Errorf("PatchPageSection(version %d) expected error", version)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("server received %d requests, want none", calls)
+	}
+
+	if _, err := client.UpdatePage(context.Background(), "1", "", "<p>x</p>", 2, ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("UpdatePage(stale version) error = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeploymentType Confluence部署类型
+type DeploymentType string
+
+const (
+	DeploymentUnknown DeploymentType = ""
+	DeploymentCloud   DeploymentType = "cloud"  // Confluence Cloud（atlassian.net）
+	DeploymentServer  DeploymentType = "server" // Confluence Server / Data Center
+)
+
+const (
+	deploymentProbeTimeout  = 5 * time.Second  // 单次探测请求的超时
+	deploymentDetectTimeout = 10 * time.Second // 一次探测（所有候选地址）的总时间上限
+	deploymentRetryInterval = 10 * time.Minute // 探测失败的结果缓存时长，过期后重新探测
+)
+
+// deploymentInfo 部署探测结果
+type deploymentInfo struct {
+	BaseURL string
+	Type    DeploymentType
+	Expires time.Time // 探测失败时的缓存过期时间，成功的结果不过期
+}
+
+// deploymentCache 按用户提供的地址缓存探测结果（包括失败结果），避免每次工具调用都重复探测
+var deploymentCache sync.Map
+
+// urlPathMarkers 页面或接口地址中标志Confluence上下文路径结束的路径段
+// 用户常直接粘贴页面地址（如 /display/KEY/Title、/wiki/spaces/KEY/pages/123），在这些路径段处截断
+var urlPathMarkers = []string{"rest", "display", "pages", "spaces", "plugins", "download", "x", "dashboard.action", "login.action"}
+
+// normalizeBaseURL 规范化用户提供的Confluence地址：去掉结尾的斜杠、接口路径和页面路径
+func normalizeBaseURL(baseURL string) string {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, segment := range segments {
+		if containsString(urlPathMarkers, strings.ToLower(segment)) {
+			segments = segments[:i]
+			break
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	if u.Path != "" {
+		u.Path = "/" + u.Path
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimRight(u.String(), "/")
+}
+
+// isCloudHost 判断是否为Atlassian Cloud托管的站点
+func isCloudHost(host string) bool {
+	host = strings.ToLower(host)
+	return strings.HasSuffix(host, ".atlassian.net") || strings.HasSuffix(host, ".jira.com")
+}
+
+// DetectDeployment 识别Confluence部署类型并规范化 BaseURL
+// Cloud 站点的接口固定在 /wiki 下；Server/Data Center 可能部署在任意上下文路径（如 /confluence），
+// 依次探测候选地址的 /rest/api/settings/systemInfo（仅Cloud提供）和 /rest/api/space，找到接口所在位置
+// 探测失败时保留原地址，由后续的实际请求报告错误；失败结果缓存 deploymentRetryInterval 后再重新探测
+func (c *ConfluenceClient) DetectDeployment(ctx context.Context) DeploymentType {
+	key := c.BaseURL
+	if cached, ok := deploymentCache.Load(key); ok {
+		info := cached.(deploymentInfo)
+		if info.Expires.IsZero() || time.Now().Before(info.Expires) {
+			c.BaseURL, c.Deployment = info.BaseURL, info.Type
+			return c.Deployment
+		}
+	}
+
+	detectCtx, cancel := context.WithTimeout(ctx, deploymentDetectTimeout)
+	defer cancel()
+	info := c.detectDeployment(detectCtx)
+	c.BaseURL, c.Deployment = info.BaseURL, info.Type
+
+	switch {
+	case info.Type != DeploymentUnknown:
+		deploymentCache.Store(key, info)
+		log.Printf("检测到 Confluence 部署类型: %s (%s)", info.Type, info.BaseURL)
+	case ctx.Err() == nil:
+		// 工具调用本身被取消时不缓存，其余失败（登录页、代理、网络慢等）在一段时间内不再重复探测
+		info.Expires = time.Now().Add(deploymentRetryInterval)
+		deploymentCache.Store(key, info)
+		log.Printf("无法识别 Confluence 部署类型 (%s)，%v 内使用原地址", info.BaseURL, deploymentRetryInterval)
+	}
+	return info.Type
+}
+
+// detectDeployment 执行部署类型探测
+func (c *ConfluenceClient) detectDeployment(ctx context.Context) deploymentInfo {
+	base := normalizeBaseURL(c.BaseURL)
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return deploymentInfo{BaseURL: base}
+	}
+
+	if isCloudHost(u.Host) {
+		return deploymentInfo{BaseURL: u.Scheme + "://" + u.Host + "/wiki", Type: DeploymentCloud}
+	}
+
+	candidates := []string{base}
+	if u.Path == "" {
+		candidates = append(candidates, base+"/wiki", base+"/confluence")
+	}
+	for _, candidate := range candidates {
+		if deployment := c.probeDeployment(ctx, candidate); deployment != DeploymentUnknown {
+			return deploymentInfo{BaseURL: candidate, Type: deployment}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return deploymentInfo{BaseURL: base}
+}
+
+// probeDeployment 探测指定地址下的REST接口
+// systemInfo 返回 cloudId 时为Cloud（使用自定义域名的Cloud站点）；接口存在但不是Cloud时为Server/Data Center
+func (c *ConfluenceClient) probeDeployment(ctx context.Context, base string) DeploymentType {
+	status, body := c.probe(ctx, base+"/rest/api/settings/systemInfo")
+	if status == http.StatusOK {
+		var systemInfo struct {
+			CloudID string `json:"cloudId"`
+		}
+		if json.Unmarshal(body, &systemInfo) == nil && systemInfo.CloudID != "" {
+			return DeploymentCloud
+		}
+		return DeploymentServer
+	}
+
+	status, body = c.probe(ctx, base+"/rest/api/space?limit=1")
+	if status == http.StatusOK || status == http.StatusUnauthorized || status == http.StatusForbidden {
+		// 认证失败时也可能返回登录页面，只有JSON响应才说明接口确实在这个位置
+		if json.Valid(body) {
+			return DeploymentServer
+		}
+	}
+	return DeploymentUnknown
+}
+
+// probe 发送不重试的探测请求，返回状态码和响应体，请求失败时状态码为 0
+func (c *ConfluenceClient) probe(ctx context.Context, endpoint string) (int, []byte) {
+	ctx, cancel := context.WithTimeout(ctx, deploymentProbeTimeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, nil
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, body
+}
+
+// supportsUserLookup 判断当前部署是否支持按该参数查询用户
+// Cloud 出于隐私要求只支持 accountId；Server/Data Center 没有 accountId，只支持 key 和 username
+func (c *ConfluenceClient) supportsUserLookup(param string) bool {
+	switch c.Deployment {
+	case DeploymentCloud:
+		return param == "accountId"
+	case DeploymentServer:
+		return param != "accountId"
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://confluence.example.com/", "https://confluence.example.com"},
+		{"https://example.com/confluence/display/KEY/Page?x=1", "https://example.com/confluence"},
+		{"https://acme.atlassian.net/wiki/spaces/KEY/pages/123/Title", "https://acme.atlassian.net/wiki"},
+		{"https://example.com/rest/api", "https://example.com"},
+		{"not a url", "not a url"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeBaseURL(tt.in); got != tt.want {
+			t.Errorf("normalizeBaseURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDetectDeploymentContextPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/confluence/rest/api/space" {
+			w.Write([]byte(`{"results":[]}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := NewConfluenceClientWithCredentials(server.URL+"/", "user", "token")
+	if got := client.DetectDeployment(context.Background()); got != DeploymentServer {
+		t.Fatalf("DetectDeployment() = %q, want %q", got, DeploymentServer)
+	}
+	if want := server.URL + "/confluence"; client.BaseURL != want {
+		t.Errorf("BaseURL = %q, want %q", client.BaseURL, want)
+	}
+}
+
+func TestDetectDeploymentCachesFailure(t *testing.T) {
+	probes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`<html>login</html>`))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		client := NewConfluenceClientWithCredentials(server.URL, "user", "token")
+		if got := client.DetectDeployment(context.Background()); got != DeploymentUnknown {
+			t.Fatalf("DetectDeployment() = %q, want unknown", got)
+		}
+		if client.BaseURL != server.URL {
+			t.Errorf("BaseURL = %q, want %q", client.BaseURL, server.URL)
+		}
+	}
+	if probes != 6 {
+		t.Errorf("server received %d probes, want 6 (one detection across 3 candidates)", probes)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStorageLines(t *testing.T) {
+	got := splitStorageLines(`<h1>A</h1><p>one <strong>b</strong></p><hr/><ul><li>x</li></ul>`)
+	want := []string{"<h1>A</h1>", "<p>one <strong>b</strong></p>", "<hr/>", "<ul><li>x</li>", "</ul>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStorageLines() = %q, want %q", got, want)
+	}
+	if got := splitStorageLines(""); got != nil {
+		t.Errorf("splitStorageLines(\"\") = %q, want nil", got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int) []string {
+		var result []string
+		for i := 1; i <= n; i++ {
+			result = append(result, strings.Repeat("x", i))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		old, new []string
+		want     string
+	}{
+		{
+			name: "identical",
+			old:  []string{"a", "b"},
+			new:  []string{"a", "b"},
+			want: "",
+		},
+		{
+			name: "create from empty",
+			old:  nil,
+			new:  []string{"a", "b"},
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "delete everything",
+			old:  []string{"a", "b"},
+			new:  nil,
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "change in the middle with context",
+			old:  []string{"1", "2", "3", "4", "5", "6", "7"},
+			new:  []string{"1", "2", "3", "X", "5", "6", "7"},
+			want: "--- old\n+++ new\n@@ -1,7 +1,7 @@\n 1\n 2\n 3\n-4\n+X\n 5\n 6\n 7\n",
+		},
+		{
+			name: "distant changes produce separate hunks",
+			old:  lines(12),
+			new:  append(append([]string{"A"}, lines(12)[1:11]...), "B"),
+			want: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,4 @@\n-x\n+A\n xx\n xxx\n xxxx\n" +
+				"@@ -9,4 +9,4 @@\n xxxxxxxxx\n xxxxxxxxxx\n xxxxxxxxxxx\n-xxxxxxxxxxxx\n+B\n",
+		},
+		{
+			name: "insertion keeps common lines",
+			old:  []string{"a", "c"},
+			new:  []string{"a", "b", "c"},
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.old, tt.new); got != tt.want {
+				t.Errorf("unifiedDiff()\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesReconstructsInputs(t *testing.T) {
+	a := []string{"a", "b", "c", "d", "e", "f"}
+	b := []string{"b", "x", "c", "e", "f", "g"}
+
+	var gotA, gotB []string
+	for _, op := range diffLines(a, b) {
+		if op.kind != '+' {
+			gotA = append(gotA, op.text)
+		}
+		if op.kind != '-' {
+			gotB = append(gotB, op.text)
+		}
+	}
+	if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+		t.Errorf("diffLines() does not reconstruct inputs: %q / %q", gotA, gotB)
+	}
+}
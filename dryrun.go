@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// DryRunResult 写操作的预览结果，不会修改Confluence中的任何内容
+type DryRunResult struct {
+	DryRun         bool   `json:"dry_run"`
+	Action         string `json:"action"`
+	PageID         string `json:"page_id,omitempty"`
+	Title          string `json:"title,omitempty"`
+	CurrentVersion int    `json:"current_version,omitempty"`
+	NewVersion     int    `json:"new_version,omitempty"`
+	Storage        string `json:"storage"`
+	Diff           string `json:"diff"`
+}
+
+// PreviewCreate 预览创建页面或评论，差异相对于空内容
+func PreviewCreate(action, title, storage string) *DryRunResult {
+	return &DryRunResult{
+		DryRun:  true,
+		Action:  action,
+		Title:   title,
+		Storage: storage,
+		Diff:    unifiedDiff("/dev/null", "new", nil, splitStorageLines(storage)),
+	}
+}
+
+// PreviewUpdatePage 预览页面更新：执行与 UpdatePage 相同的版本检查，返回最终内容和相对当前版本的差异
+func (c *ConfluenceClient) PreviewUpdatePage(ctx context.Context, pageID, title, content string, expectedVersion int) (*DryRunResult, error) {
+	current, err := c.currentPageForUpdate(ctx, pageID, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		content = current.Body.Storage.Value
+	}
+	return previewPageChange("update_page", current, title, content), nil
+}
+
+// PreviewPatchPageSection 预览章节修改：执行与 PatchPageSection 相同的版本检查和章节定位
+func (c *ConfluenceClient) PreviewPatchPageSection(ctx context.Context, pageID, path, mode, content string, expectedVersion int) (*DryRunResult, error) {
+	current, err := c.currentPageForUpdate(ctx, pageID, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	body, err := patchSection(current.Body.Storage.Value, path, mode, content)
+	if err != nil {
+		return nil, err
+	}
+	return previewPageChange("patch_page_section", current, "", body), nil
+}
+
+// previewPageChange 生成页面修改的预览，标题变化也体现在差异中
+func previewPageChange(action string, current *PageResponse, title, storage string) *DryRunResult {
+	if title == "" {
+		title = current.Title
+	}
+
+	oldLines := append([]string{"title: " + current.Title}, splitStorageLines(current.Body.Storage.Value)...)
+	newLines := append([]string{"title: " + title}, splitStorageLines(storage)...)
+
+	return &DryRunResult{
+		DryRun:         true,
+		Action:         action,
+		PageID:         current.ID,
+		Title:          title,
+		CurrentVersion: current.Version.Number,
+		NewVersion:     current.Version.Number + 1,
+		Storage:        storage,
+		Diff: unifiedDiff(
+			fmt.Sprintf("version %d", current.Version.Number),
+			fmt.Sprintf("version %d", current.Version.Number+1),
+			oldLines, newLines,
+		),
+	}
+}
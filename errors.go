@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// API错误类别，使用 errors.Is 判断
+var (
+	ErrBadRequest   = errors.New("请求无效")
+	ErrUnauthorized = errors.New("认证失败")
+	ErrForbidden    = errors.New("没有权限")
+	ErrNotFound     = errors.New("内容不存在")
+	ErrConflict     = errors.New("内容冲突")
+	ErrRateLimited  = errors.New("请求被限流")
+)
+
+// maxErrorBodyLength 错误信息中保留的响应体长度上限
+const maxErrorBodyLength = 300
+
+// APIError Confluence API 返回的错误响应
+type APIError struct {
+	StatusCode int
+	Body       string
+	Message    string        // 响应中的 message 字段
+	Errors     []string      // 响应中 data.errors 的具体错误（如存储格式或CQL的校验错误）
+	RetryAfter time.Duration // 限流时服务端要求的等待时间
+}
+
+// confluenceErrorResponse Confluence REST API 的错误响应体
+type confluenceErrorResponse struct {
+	Message string `json:"message"`
+	Data    struct {
+		Errors []struct {
+			Message struct {
+				Key         string `json:"key"`
+				Translation string `json:"translation"`
+			} `json:"message"`
+		} `json:"errors"`
+	} `json:"data"`
+}
+
+// newAPIError 根据错误响应创建 APIError，解析 Confluence 返回的 message 和 data.errors
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: retryAfter(resp.Header, time.Now()),
+	}
+
+	var errResp confluenceErrorResponse
+	if json.Unmarshal(body, &errResp) == nil {
+		apiErr.Message = strings.TrimSpace(errResp.Message)
+		for _, e := range errResp.Data.Errors {
+			msg := e.Message.Translation
+			if msg == "" {
+				msg = e.Message.Key
+			}
+			if msg != "" && msg != apiErr.Message {
+				apiErr.Errors = append(apiErr.Errors, msg)
+			}
+		}
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	detail := strings.Join(e.Errors, "; ")
+	if e.Message != "" && detail != "" {
+		detail = e.Message + ": " + detail
+	} else if e.Message != "" {
+		detail = e.Message
+	}
+	if detail == "" {
+		detail = summarizeErrorBody(e.Body)
+	}
+	return fmt.Sprintf("API 请求失败 (状态码: %d): %s", e.StatusCode, detail)
+}
+
+// Unwrap 返回错误类别，使 errors.Is(err, ErrNotFound) 等判断可用
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// htmlTagRe 匹配HTML标签，用于从HTML错误页中提取文本
+var htmlTagRe = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]+>`)
+
+// summarizeErrorBody 将非JSON的错误响应（通常是网关或登录的HTML页面）压缩为简短文本
+func summarizeErrorBody(body string) string {
+	text := body
+	if strings.Contains(text, "<") {
+		text = htmlTagRe.ReplaceAllString(text, " ")
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) > maxErrorBodyLength {
+		text = string([]rune(text)[:maxErrorBodyLength]) + "..."
+	}
+	return text
+}
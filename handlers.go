@@ -3,14 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func handleGetPage() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		client, err := getClientFromContext(request)
+		client, err := getClientFromContext(ctx, request)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
 		}
@@ -20,10 +22,18 @@ func handleGetPage() func(ctx context.Context, request mcp.CallToolRequest) (*mc
 			return mcp.NewToolResultError("page_id is required"), nil
 		}
 
+		opts := MarkdownOptions{
+			IncludeAttachments: request.GetBool("include_attachments", false),
+			ColumnSeparators:   request.GetBool("column_separators", false),
+			ExpandJira:         request.GetBool("expand_jira", false),
+			ExpandIncludes:     request.GetBool("expand_includes", false),
+			IncludeDepth:       request.GetInt("include_depth", 0),
+		}
+
 		// 直接转换为Markdown格式
-		markdownPage, err := client.ConvertPageToMarkdown(pageID)
+		markdownPage, err := client.ConvertPageToMarkdown(ctx, pageID, opts)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to get page as markdown: %v", err)), nil
+			return toolError("Failed to get page as markdown", err), nil
 		}
 
 		// 返回纯Markdown文本内容
@@ -33,7 +43,7 @@ func handleGetPage() func(ctx context.Context, request mcp.CallToolRequest) (*mc
 
 func handleGetChildPages() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		client, err := getClientFromContext(request)
+		client, err := getClientFromContext(ctx, request)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
 		}
@@ -46,9 +56,9 @@ func handleGetChildPages() func(ctx context.Context, request mcp.CallToolRequest
 		limit := request.GetInt("limit", 25)
 		start := request.GetInt("start", 0)
 
-		children, err := client.GetChildPages(pageID, limit, start)
+		children, err := client.GetChildPages(ctx, pageID, limit, start)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to get child pages: %v", err)), nil
+			return toolError("Failed to get child pages", err), nil
 		}
 
 		result, _ := json.Marshal(children)
@@ -58,7 +68,7 @@ func handleGetChildPages() func(ctx context.Context, request mcp.CallToolRequest
 
 func handleCreatePage() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		client, err := getClientFromContext(request)
+		client, err := getClientFromContext(ctx, request)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
 		}
@@ -80,9 +90,121 @@ func handleCreatePage() func(ctx context.Context, request mcp.CallToolRequest) (
 
 		parentID := request.GetString("parent_id", "")
 
-		page, err := client.CreatePage(title, content, spaceKey, parentID)
+		body, err := client.ToStorageFormat(ctx, content, request.GetString("content_format", "storage"))
+		if err != nil {
+			return toolError("Invalid content", err), nil
+		}
+
+		if request.GetBool("dry_run", false) {
+			result, _ := json.Marshal(PreviewCreate("create_page", title, body))
+			return mcp.NewToolResultText(string(result)), nil
+		}
+
+		page, err := client.CreatePage(ctx, title, body, spaceKey, parentID)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create page: %v", err)), nil
+			return toolError("Failed to create page", err), nil
+		}
+
+		result, _ := json.Marshal(page)
+		return mcp.NewToolResultText(string(result)), nil
+	}
+}
+
+func handleUpdatePage() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client, err := getClientFromContext(ctx, request)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
+		}
+
+		pageID, err := request.RequireString("page_id")
+		if err != nil {
+			return mcp.NewToolResultError("page_id is required"), nil
+		}
+
+		version, err := request.RequireInt("version")
+		if err != nil || version <= 0 {
+			return mcp.NewToolResultError("version is required (the page version you last read)"), nil
+		}
+
+		title := request.GetString("title", "")
+		content := request.GetString("content", "")
+		if title == "" && content == "" {
+			return mcp.NewToolResultError("title or content is required"), nil
+		}
+
+		body := ""
+		if content != "" {
+			body, err = client.ToStorageFormat(ctx, content, request.GetString("content_format", "storage"))
+			if err != nil {
+				return toolError("Invalid content", err), nil
+			}
+		}
+
+		if request.GetBool("dry_run", false) {
+			preview, err := client.PreviewUpdatePage(ctx, pageID, title, body, version)
+			if err != nil {
+				return toolError("Failed to preview page update", err), nil
+			}
+			result, _ := json.Marshal(preview)
+			return mcp.NewToolResultText(string(result)), nil
+		}
+
+		page, err := client.UpdatePage(ctx, pageID, title, body, version, request.GetString("version_message", ""))
+		if err != nil {
+			return toolError("Failed to update page", err), nil
+		}
+
+		result, _ := json.Marshal(page)
+		return mcp.NewToolResultText(string(result)), nil
+	}
+}
+
+func handlePatchPageSection() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client, err := getClientFromContext(ctx, request)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
+		}
+
+		pageID, err := request.RequireString("page_id")
+		if err != nil {
+			return mcp.NewToolResultError("page_id is required"), nil
+		}
+
+		heading, err := request.RequireString("heading")
+		if err != nil {
+			return mcp.NewToolResultError("heading is required"), nil
+		}
+
+		content, err := request.RequireString("content")
+		if err != nil {
+			return mcp.NewToolResultError("content is required"), nil
+		}
+
+		version, err := request.RequireInt("version")
+		if err != nil || version <= 0 {
+			return mcp.NewToolResultError("version is required (the page version you last read)"), nil
+		}
+
+		body, err := client.ToStorageFormat(ctx, content, request.GetString("content_format", "storage"))
+		if err != nil {
+			return toolError("Invalid content", err), nil
+		}
+
+		mode := request.GetString("mode", "replace")
+		if request.GetBool("dry_run", false) {
+			preview, err := client.PreviewPatchPageSection(ctx, pageID, heading, mode, body, version)
+			if err != nil {
+				return toolError("Failed to preview page section patch", err), nil
+			}
+			result, _ := json.Marshal(preview)
+			return mcp.NewToolResultText(string(result)), nil
+		}
+
+		page, err := client.PatchPageSection(ctx, pageID, heading, mode, body, version, request.GetString("version_message", ""))
+		if err != nil {
+			return toolError("Failed to patch page section", err), nil
 		}
 
 		result, _ := json.Marshal(page)
@@ -92,7 +214,7 @@ func handleCreatePage() func(ctx context.Context, request mcp.CallToolRequest) (
 
 func handleCreateComment() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		client, err := getClientFromContext(request)
+		client, err := getClientFromContext(ctx, request)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
 		}
@@ -107,9 +229,19 @@ func handleCreateComment() func(ctx context.Context, request mcp.CallToolRequest
 			return mcp.NewToolResultError("comment is required"), nil
 		}
 
-		result, err := client.CreateComment(pageID, comment)
+		body, err := client.ToStorageFormat(ctx, comment, request.GetString("content_format", "storage"))
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create comment: %v", err)), nil
+			return toolError("Invalid comment", err), nil
+		}
+
+		if request.GetBool("dry_run", false) {
+			result, _ := json.Marshal(PreviewCreate("create_comment", "", body))
+			return mcp.NewToolResultText(string(result)), nil
+		}
+
+		result, err := client.CreateComment(ctx, pageID, body)
+		if err != nil {
+			return toolError("Failed to create comment", err), nil
 		}
 
 		response, _ := json.Marshal(result)
@@ -119,7 +251,7 @@ func handleCreateComment() func(ctx context.Context, request mcp.CallToolRequest
 
 func handleSearchPages() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		client, err := getClientFromContext(request)
+		client, err := getClientFromContext(ctx, request)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
 		}
@@ -133,9 +265,9 @@ func handleSearchPages() func(ctx context.Context, request mcp.CallToolRequest)
 		spaceKey := request.GetString("space_key", "")
 		start := request.GetInt("start", 0)
 
-		pages, err := client.SearchPages(query, spaceKey, limit, start)
+		pages, err := client.SearchPages(ctx, query, spaceKey, limit, start)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to search pages: %v", err)), nil
+			return toolError("Failed to search pages", err), nil
 		}
 
 		result, _ := json.Marshal(pages)
@@ -145,7 +277,7 @@ func handleSearchPages() func(ctx context.Context, request mcp.CallToolRequest)
 
 func handleConvertPageToMarkdown() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		client, err := getClientFromContext(request)
+		client, err := getClientFromContext(ctx, request)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
 		}
@@ -155,9 +287,17 @@ func handleConvertPageToMarkdown() func(ctx context.Context, request mcp.CallToo
 			return mcp.NewToolResultError("page_id is required"), nil
 		}
 
-		markdownPage, err := client.ConvertPageToMarkdown(pageID)
+		opts := MarkdownOptions{
+			IncludeAttachments: request.GetBool("include_attachments", false),
+			ColumnSeparators:   request.GetBool("column_separators", false),
+			ExpandJira:         request.GetBool("expand_jira", false),
+			ExpandIncludes:     request.GetBool("expand_includes", false),
+			IncludeDepth:       request.GetInt("include_depth", 0),
+		}
+
+		markdownPage, err := client.ConvertPageToMarkdown(ctx, pageID, opts)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to convert page to markdown: %v", err)), nil
+			return toolError("Failed to convert page to markdown", err), nil
 		}
 
 		result, _ := json.Marshal(markdownPage)
@@ -165,8 +305,67 @@ func handleConvertPageToMarkdown() func(ctx context.Context, request mcp.CallToo
 	}
 }
 
+func handleListTasks() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client, err := getClientFromContext(ctx, request)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
+		}
+
+		pageID := request.GetString("page_id", "")
+		cql := request.GetString("cql", "")
+		if pageID == "" && cql == "" {
+			return mcp.NewToolResultError("page_id or cql is required"), nil
+		}
+
+		status := request.GetString("status", "all")
+		assignee := request.GetString("assignee", "")
+		limit := request.GetInt("limit", 25)
+
+		tasks, err := client.ListTasks(ctx, pageID, cql, limit)
+		if err != nil {
+			return toolError("Failed to list tasks", err), nil
+		}
+
+		result, _ := json.Marshal(filterTasks(tasks, status, assignee))
+		return mcp.NewToolResultText(string(result)), nil
+	}
+}
+
+func handleGetPageProperties() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client, err := getClientFromContext(ctx, request)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("认证失败: %v", err)), nil
+		}
+
+		pageID := request.GetString("page_id", "")
+		cql := request.GetString("cql", "")
+		if label := request.GetString("label", ""); cql == "" && label != "" {
+			cql = fmt.Sprintf("label = %s and type = page", quoteCQL(label))
+			if spaceKey := request.GetString("space_key", ""); spaceKey != "" {
+				cql += fmt.Sprintf(" and space = %s", quoteCQL(spaceKey))
+			}
+		}
+		if pageID == "" && cql == "" {
+			return mcp.NewToolResultError("page_id, cql or label is required"), nil
+		}
+
+		detailsID := request.GetString("details_id", "")
+		limit := request.GetInt("limit", 25)
+
+		properties, err := client.GetPageProperties(ctx, pageID, cql, detailsID, limit)
+		if err != nil {
+			return toolError("Failed to get page properties", err), nil
+		}
+
+		result, _ := json.Marshal(properties)
+		return mcp.NewToolResultText(string(result)), nil
+	}
+}
+
 // getClientFromContext 从上下文中获取用户凭据并创建客户端
-func getClientFromContext(request mcp.CallToolRequest) (*ConfluenceClient, error) {
+func getClientFromContext(ctx context.Context, request mcp.CallToolRequest) (*ConfluenceClient, error) {
 
 	headers := request.Header
 
@@ -176,9 +375,54 @@ func getClientFromContext(request mcp.CallToolRequest) (*ConfluenceClient, error
 	apiToken := headers.Get("X-Confluence-Token")
 
 	client := NewConfluenceClientWithCredentials(baseURL, name, apiToken)
+
+	// 认证方式：basic、bearer（Data Center 个人访问令牌）或 anonymous，未指定时根据凭据推断
+	authType, err := ParseAuthType(headers.Get("X-Confluence-Auth-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("confluence auth failed: %v", err)
+	}
+	client.AuthType = authType
+
 	if err := client.ValidateCredentials(); err != nil {
 		return nil, fmt.Errorf("confluence auth failed: %v", err)
 	}
 
+	// 识别 Cloud 或 Server/Data Center，并修正地址中缺少的 /wiki 或上下文路径
+	client.DetectDeployment(ctx)
+
+	// 可选的Jira配置，用于展开Jira宏
+	if jiraBaseURL := headers.Get("X-Jira-Base-URL"); jiraBaseURL != "" {
+		client.Jira = NewJiraClient(jiraBaseURL, headers.Get("X-Jira-Authorization"))
+	}
+
 	return client, nil
 }
+
+// toolError 将错误转换为工具错误结果，并根据错误类别附加下一步操作建议
+func toolError(action string, err error) *mcp.CallToolResult {
+	message := fmt.Sprintf("%s: %v", action, err)
+
+	var conflict *VersionConflictError
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &conflict):
+		message += "\nRe-read the page with get_page_and_comment to get the latest version, then apply your change to it."
+	case errors.Is(err, ErrNotFound):
+		message += "\nThe page, space or content does not exist, or the current user cannot view it. Check the ID or space key, or use search_pages to find the page by title."
+	case errors.Is(err, ErrUnauthorized):
+		message += "\nConfluence rejected the credentials. Check the X-Confluence-Name, X-Confluence-Token and X-Confluence-Auth-Type headers; Confluence Cloud requires an email with an API token, Data Center personal access tokens use bearer auth, and anonymous access only works for public spaces."
+	case errors.Is(err, ErrForbidden):
+		message += "\nThe current user has no permission for this page or space. Choose content the user can access, or ask a space administrator to grant permission."
+	case errors.Is(err, ErrConflict):
+		message += "\nThe change conflicts with existing content, for example a page with the same title already exists in the space. Use a different title, or update the existing page instead."
+	case errors.Is(err, ErrRateLimited):
+		wait := "a while"
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter.Round(time.Second).String()
+		}
+		message += fmt.Sprintf("\nConfluence is rate limiting requests. Wait %s before retrying and reduce the number of calls, e.g. use smaller limits.", wait)
+	case errors.Is(err, ErrBadRequest):
+		message += "\nConfluence rejected the request. Fix the problems listed above: content must be well-formed storage format XHTML (or use content_format=markdown), and CQL queries must use valid syntax."
+	}
+	return mcp.NewToolResultError(message)
+}
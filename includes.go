@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultIncludeDepth 未指定时嵌入页面的最大深度
+const defaultIncludeDepth = 3
+
+// includeDepth 返回嵌入页面的最大深度
+func (m *markdownConverter) includeDepth() int {
+	if m.opts.IncludeDepth > 0 {
+		return m.opts.IncludeDepth
+	}
+	return defaultIncludeDepth
+}
+
+// macroPageRef 返回宏参数中通过 ac:link 引用的页面（include、excerpt-include、children 等宏使用）
+func macroPageRef(n *storageNode) *storageNode {
+	for _, p := range n.childrenNamed("ac:parameter") {
+		for _, link := range p.childrenNamed("ac:link") {
+			if page := link.child("ri:page"); page != nil {
+				return page
+			}
+		}
+	}
+	return nil
+}
+
+// renderIncludeMacro 渲染 include 和 excerpt-include 宏
+// 启用 ExpandIncludes 时获取被引用页面并内联其转换后的内容（excerpt-include 只内联摘要），
+// 否则只输出指向被引用页面的链接
+func (m *markdownConverter) renderIncludeMacro(n *storageNode) string {
+	target := macroPageRef(n)
+	if target == nil {
+		return ""
+	}
+	title := target.attr("ri:content-title")
+	href, _ := m.contentLink(target, "page")
+	if !m.opts.ExpandIncludes {
+		return quoteLines(fmt.Sprintf("引用页面: [%s](%s)", title, href))
+	}
+
+	ref := m.resolveContent(target, "page")
+	if ref == nil {
+		return quoteLines(fmt.Sprintf("[!WARNING]\n无法获取引用页面: [%s](%s)", title, href))
+	}
+	return m.renderIncludedPage(ref.ID, title, n.macroName() == "excerpt-include")
+}
+
+// renderIncludedPage 获取并转换被嵌入的页面，带深度限制和循环检测
+// 转换期间切换当前页面上下文，使嵌入内容中的附件和相对链接指向被嵌入页面
+func (m *markdownConverter) renderIncludedPage(pageID, title string, excerptOnly bool) string {
+	if pageID == m.pageID || containsString(m.includeStack, pageID) {
+		return quoteLines(fmt.Sprintf("[!WARNING]\n检测到循环引用，已跳过: %s", title))
+	}
+	if len(m.includeStack) >= m.includeDepth() {
+		return quoteLines(fmt.Sprintf("[!NOTE]\n已达到嵌入深度上限（%d），未展开: %s", m.includeDepth(), title))
+	}
+
+	page, err := m.client.GetPageContent(m.ctx, pageID)
+	if err != nil {
+		return quoteLines(fmt.Sprintf("[!WARNING]\n无法获取引用页面: %s", title))
+	}
+
+	parentID, parentSpace := m.pageID, m.spaceKey
+	m.includeStack = append(m.includeStack, parentID)
+	m.setPage(page)
+	defer func() {
+		m.includeStack = m.includeStack[:len(m.includeStack)-1]
+		m.pageID, m.spaceKey = parentID, parentSpace
+	}()
+
+	root := parseStorage(page.Body.Storage.Value)
+	nodes := root.Children
+	if excerptOnly {
+		nodes = nil
+		for _, macro := range findElements(root, "ac:structured-macro") {
+			if macro.macroName() == "excerpt" {
+				if body := macro.child("ac:rich-text-body"); body != nil {
+					nodes = body.Children
+				}
+				break
+			}
+		}
+	}
+	return m.renderBlocks(nodes)
+}
+
+// renderChildrenMacro 渲染 children 和 pagetree 宏为子页面标题列表
+// 未启用 ExpandIncludes 时不发起请求；depth 参数控制 children 的层级，pagetree 使用嵌入深度上限
+func (m *markdownConverter) renderChildrenMacro(n *storageNode) string {
+	if !m.opts.ExpandIncludes {
+		return m.renderMacroPlaceholder(n, n.macroParams())
+	}
+
+	rootID := m.pageID
+	if target := macroPageRef(n); target != nil {
+		if ref := m.resolveContent(target, "page"); ref != nil {
+			rootID = ref.ID
+		}
+	}
+	if rootID == "" {
+		return ""
+	}
+
+	depth := 1
+	if n.macroName() == "pagetree" {
+		depth = m.includeDepth()
+	} else if v, err := strconv.Atoi(n.macroParams()["depth"]); err == nil && v > 0 {
+		depth = min(v, m.includeDepth())
+	} else if n.macroParams()["all"] == "true" {
+		depth = m.includeDepth()
+	}
+
+	return m.renderChildTree(rootID, depth, map[string]bool{rootID: true})
+}
+
+// renderChildTree 递归渲染子页面树，visited 用于防止循环
+func (m *markdownConverter) renderChildTree(pageID string, depth int, visited map[string]bool) string {
+	children, err := m.client.GetChildPages(m.ctx, pageID, 100, 0)
+	if err != nil {
+		return ""
+	}
+
+	var lines []string
+	for _, child := range children.Results {
+		if visited[child.ID] {
+			continue
+		}
+		visited[child.ID] = true
+		lines = append(lines, fmt.Sprintf("- [%s](%s \"page_id: %s\")", escapeMarkdown(child.Title), m.client.BaseURL+child.Links.Webui, child.ID))
+		if depth > 1 {
+			if sub := m.renderChildTree(child.ID, depth-1, visited); sub != "" {
+				lines = append(lines, indentLines(sub, "  "))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// containsString 判断列表是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JiraClient 用于展开Jira宏的Jira API客户端
+type JiraClient struct {
+	BaseURL    string
+	AuthHeader string // 原样作为 Authorization 头发送，如 "Bearer xxx" 或 "Basic xxx"
+	HTTPClient *http.Client
+}
+
+// NewJiraClient 创建Jira客户端
+func NewJiraClient(baseURL, authHeader string) *JiraClient {
+	return &JiraClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		AuthHeader: authHeader,
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// JiraIssue Jira问题信息
+type JiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Status  struct {
+			Name string `json:"name"`
+		} `json:"status"`
+	} `json:"fields"`
+}
+
+// makeRequest 发送 Jira API 请求并解析JSON响应
+func (j *JiraClient) makeRequest(ctx context.Context, endpoint string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", j.BaseURL+"/rest/api/2"+endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	if j.AuthHeader != "" {
+		req.Header.Set("Authorization", j.AuthHeader)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := j.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Jira API 请求失败 (状态码: %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("解析Jira响应失败: %w", err)
+	}
+	return nil
+}
+
+// GetIssue 获取单个问题的摘要和状态
+func (j *JiraClient) GetIssue(ctx context.Context, key string) (*JiraIssue, error) {
+	var issue JiraIssue
+	if err := j.makeRequest(ctx, fmt.Sprintf("/issue/%s?fields=summary,status", url.PathEscape(key)), &issue); err != nil {
+		return nil, fmt.Errorf("获取Jira问题失败: %w", err)
+	}
+	return &issue, nil
+}
+
+// SearchIssues 使用JQL搜索问题
+func (j *JiraClient) SearchIssues(ctx context.Context, jql string, limit int) ([]JiraIssue, error) {
+	params := url.Values{}
+	params.Set("jql", jql)
+	params.Set("maxResults", strconv.Itoa(limit))
+	params.Set("fields", "summary,status")
+
+	var searchResp struct {
+		Issues []JiraIssue `json:"issues"`
+	}
+	if err := j.makeRequest(ctx, "/search?"+params.Encode(), &searchResp); err != nil {
+		return nil, fmt.Errorf("搜索Jira问题失败: %w", err)
+	}
+	return searchResp.Issues, nil
+}
+
+// IssueURL 返回问题的浏览地址
+func (j *JiraClient) IssueURL(key string) string {
+	return j.BaseURL + "/browse/" + key
+}
+
+// SearchURL 返回JQL查询的浏览地址
+func (j *JiraClient) SearchURL(jql string) string {
+	return j.BaseURL + "/issues/?jql=" + url.QueryEscape(jql)
+}
+
+// renderJiraMacro 渲染Jira宏
+// 单个问题渲染为链接，JQL查询渲染为提示块；启用 ExpandJira 且配置了Jira客户端时内联问题摘要和状态
+func (m *markdownConverter) renderJiraMacro(n *storageNode) string {
+	params := n.macroParams()
+	jira := m.client.Jira
+
+	if key := params["key"]; key != "" {
+		text := key
+		if jira != nil {
+			text = fmt.Sprintf("[%s](%s)", key, jira.IssueURL(key))
+		}
+		if issue := m.jiraIssue(key); issue != nil {
+			text += fmt.Sprintf(" %s (%s)", escapeMarkdown(issue.Fields.Summary), issue.Fields.Status.Name)
+		}
+		return text
+	}
+
+	jql := params["jqlQuery"]
+	if jql == "" {
+		return ""
+	}
+	lines := []string{"[!NOTE]", "Jira 查询: `" + jql + "`"}
+	if jira != nil {
+		lines[1] = fmt.Sprintf("Jira 查询: [`%s`](%s)", jql, jira.SearchURL(jql))
+	}
+	if m.opts.ExpandJira && jira != nil {
+		limit := 20
+		if count, err := strconv.Atoi(params["maximumIssues"]); err == nil && count > 0 {
+			limit = count
+		}
+		if issues, err := jira.SearchIssues(m.ctx, jql, limit); err == nil {
+			lines = append(lines, "")
+			for _, issue := range issues {
+				lines = append(lines, fmt.Sprintf("- [%s](%s) %s (%s)", issue.Key, jira.IssueURL(issue.Key), escapeMarkdown(issue.Fields.Summary), issue.Fields.Status.Name))
+			}
+		}
+	}
+	return quoteLines(strings.Join(lines, "\n"))
+}
+
+// jiraIssue 查询问题详情，结果在本次请求内缓存；未启用展开或查询失败时返回 nil
+func (m *markdownConverter) jiraIssue(key string) *JiraIssue {
+	if !m.opts.ExpandJira || m.client.Jira == nil {
+		return nil
+	}
+	if issue, ok := m.jiraIssues[key]; ok {
+		return issue
+	}
+	issue, err := m.client.Jira.GetIssue(m.ctx, key)
+	if err != nil {
+		issue = nil
+	}
+	m.jiraIssues[key] = issue
+	return issue
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// renderLink 渲染 ac:link，支持用户、页面、博客、附件、空间和页内锚点链接
+func (m *markdownConverter) renderLink(n *storageNode) string {
+	if user := n.child("ri:user"); user != nil {
+		return m.renderInline(user)
+	}
+
+	text := m.linkBody(n)
+	anchor := n.attr("ac:anchor")
+
+	var href, title string
+	switch {
+	case n.child("ri:page") != nil:
+		target := n.child("ri:page")
+		href, title = m.contentLink(target, "page")
+		if text == "" {
+			text = target.attr("ri:content-title")
+		}
+	case n.child("ri:blog-post") != nil:
+		target := n.child("ri:blog-post")
+		href, title = m.contentLink(target, "blogpost")
+		if text == "" {
+			text = target.attr("ri:content-title")
+		}
+	case n.child("ri:attachment") != nil:
+		target := n.child("ri:attachment")
+		href = m.attachmentURL(target)
+		if text == "" {
+			text = target.attr("ri:filename")
+		}
+	case n.child("ri:space") != nil:
+		spaceKey := n.child("ri:space").attr("ri:space-key")
+		href = m.client.BaseURL + "/display/" + url.PathEscape(spaceKey)
+		if text == "" {
+			text = spaceKey
+		}
+	case n.child("ri:url") != nil:
+		href = n.child("ri:url").attr("ri:value")
+	}
+
+	if anchor != "" {
+		href += "#" + anchor
+		if text == "" {
+			text = anchor
+		}
+	}
+	if href == "" {
+		return text
+	}
+	if text == "" {
+		text = href
+	}
+	if title != "" {
+		return fmt.Sprintf("[%s](%s \"%s\")", text, linkDestination(href), title)
+	}
+	return fmt.Sprintf("[%s](%s)", text, linkDestination(href))
+}
+
+// linkBody 返回链接的显示文本
+func (m *markdownConverter) linkBody(n *storageNode) string {
+	if body := n.child("ac:plain-text-link-body"); body != nil {
+		return strings.TrimSpace(body.textContent())
+	}
+	if body := n.child("ac:link-body"); body != nil {
+		return strings.TrimSpace(m.renderInlineChildren(body))
+	}
+	return ""
+}
+
+// contentLink 解析页面或博客引用，返回绝对URL和包含页面ID的链接标题
+// 无法解析时退回按空间和标题拼接的 /display 地址
+func (m *markdownConverter) contentLink(target *storageNode, contentType string) (string, string) {
+	page := m.resolveContent(target, contentType)
+	if page != nil {
+		return m.client.BaseURL + page.Links.Webui, "page_id: " + page.ID
+	}
+
+	spaceKey := target.attr("ri:space-key")
+	if spaceKey == "" {
+		spaceKey = m.spaceKey
+	}
+	title := strings.ReplaceAll(url.PathEscape(target.attr("ri:content-title")), "%20", "+")
+	if day := target.attr("ri:posting-day"); contentType == "blogpost" && day != "" {
+		return fmt.Sprintf("%s/display/%s/%s/%s", m.client.BaseURL, url.PathEscape(spaceKey), strings.ReplaceAll(day, "-", "/"), title), ""
+	}
+	return fmt.Sprintf("%s/display/%s/%s", m.client.BaseURL, url.PathEscape(spaceKey), title), ""
+}
+
+// resolveContent 按空间和标题查找被引用的页面或博客，结果在本次请求内缓存
+func (m *markdownConverter) resolveContent(target *storageNode, contentType string) *PageResponse {
+	title := target.attr("ri:content-title")
+	if title == "" {
+		return nil
+	}
+	spaceKey := target.attr("ri:space-key")
+	if spaceKey == "" {
+		spaceKey = m.spaceKey
+	}
+	if spaceKey == "" {
+		return nil
+	}
+	postingDay := target.attr("ri:posting-day")
+
+	cacheKey := contentType + ":" + spaceKey + ":" + postingDay + ":" + title
+	if page, ok := m.pages[cacheKey]; ok {
+		return page
+	}
+
+	page, err := m.client.FindContentByTitle(m.ctx, spaceKey, title, contentType, postingDay)
+	if err != nil {
+		page = nil
+	}
+	m.pages[cacheKey] = page
+	return page
+}
+
+// attachmentURL 返回附件的下载地址
+// 附件可以通过嵌套的 ri:page 引用其他页面上的文件；
+// 优先使用附件接口返回的下载链接（带版本参数），找不到时按约定路径拼接
+func (m *markdownConverter) attachmentURL(attachment *storageNode) string {
+	pageID := m.pageID
+	if target := attachment.child("ri:page"); target != nil {
+		if page := m.resolveContent(target, "page"); page != nil {
+			pageID = page.ID
+		}
+	}
+	if pageID == "" {
+		return ""
+	}
+
+	filename := attachment.attr("ri:filename")
+	for _, a := range m.pageAttachments(pageID) {
+		if a.Title == filename && a.Links.Download != "" {
+			return m.client.BaseURL + a.Links.Download
+		}
+	}
+	return fmt.Sprintf("%s/download/attachments/%s/%s", m.client.BaseURL, pageID, url.PathEscape(filename))
+}
+
+// pageAttachments 获取页面附件列表，结果在本次请求内缓存
+func (m *markdownConverter) pageAttachments(pageID string) []AttachmentInfo {
+	if attachments, ok := m.attachments[pageID]; ok {
+		return attachments
+	}
+	attachments, err := m.client.GetAttachments(m.ctx, pageID)
+	if err != nil {
+		attachments = nil
+	}
+	m.attachments[pageID] = attachments
+	return attachments
+}
+
+// renderImage 渲染 ac:image，图片来源可以是附件（ri:attachment）或外部地址（ri:url）
+func (m *markdownConverter) renderImage(n *storageNode) string {
+	var src, name string
+	if attachment := n.child("ri:attachment"); attachment != nil {
+		src = m.attachmentURL(attachment)
+		name = attachment.attr("ri:filename")
+	} else if ref := n.child("ri:url"); ref != nil {
+		src = ref.attr("ri:value")
+	}
+	if src == "" {
+		return ""
+	}
+
+	alt := n.attr("ac:alt")
+	if alt == "" {
+		alt = n.attr("ac:title")
+	}
+	if alt == "" {
+		alt = name
+	}
+	return fmt.Sprintf("![%s](%s)", alt, linkDestination(src))
+}
+
+// linkDestination 返回Markdown链接地址：尖括号和换行做百分号编码，包含空白时用尖括号包裹
+func linkDestination(href string) string {
+	href = strings.NewReplacer("<", "%3C", ">", "%3E", "\n", "%0A").Replace(href)
+	if strings.ContainsAny(href, " \t") {
+		return "<" + href + ">"
+	}
+	return href
+}
+
+// renderAttachments 渲染页面附件列表
+func (m *markdownConverter) renderAttachments(pageID string) string {
+	var lines []string
+	for _, a := range m.pageAttachments(pageID) {
+		mediaType := a.Extensions.MediaType
+		if mediaType == "" {
+			mediaType = a.Metadata.MediaType
+		}
+		lines = append(lines, fmt.Sprintf("- [%s](%s) (%s, %s)", a.Title, m.client.BaseURL+a.Links.Download, mediaType, formatFileSize(a.Extensions.FileSize)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatFileSize 将字节数格式化为可读的大小
+func formatFileSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
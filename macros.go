@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// emoticons Confluence表情名到Unicode字符的映射
+var emoticons = map[string]string{
+	"smile":         "🙂",
+	"sad":           "🙁",
+	"cheeky":        "😛",
+	"laugh":         "😀",
+	"wink":          "😉",
+	"thumbs-up":     "👍",
+	"thumbs-down":   "👎",
+	"information":   "ℹ️",
+	"tick":          "✅",
+	"cross":         "❌",
+	"warning":       "⚠️",
+	"plus":          "➕",
+	"minus":         "➖",
+	"question":      "❓",
+	"light-on":      "💡",
+	"light-off":     "🔌",
+	"yellow-star":   "⭐",
+	"red-star":      "⭐",
+	"green-star":    "⭐",
+	"blue-star":     "⭐",
+	"heart":         "❤️",
+	"broken-heart":  "💔",
+	"star_yellow":   "⭐",
+	"check":         "✅",
+	"error":         "❌",
+	"thumbs_up":     "👍",
+	"thumbs_down":   "👎",
+	"lightbulb_on":  "💡",
+	"lightbulb_off": "🔌",
+}
+
+// renderEmoticon 渲染 ac:emoticon，新版编辑器的表情带有 ac:emoji-fallback 属性
+func renderEmoticon(n *storageNode) string {
+	if fallback := n.attr("ac:emoji-fallback"); fallback != "" {
+		return fallback
+	}
+	if emoji, ok := emoticons[n.attr("ac:name")]; ok {
+		return emoji
+	}
+	if shortname := n.attr("ac:emoji-shortname"); shortname != "" {
+		return shortname
+	}
+	return ""
+}
+
+// renderStatusMacro 渲染状态标签为 [STATUS: 标题]
+func renderStatusMacro(params map[string]string) string {
+	title := params["title"]
+	if title == "" {
+		title = params["colour"]
+	}
+	if title == "" {
+		return ""
+	}
+	return "[STATUS: " + strings.ToUpper(title) + "]"
+}
+
+// renderAnchorMacro 渲染锚点宏为HTML锚点
+func renderAnchorMacro(params map[string]string) string {
+	name := params[""]
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(`<a id="%s"></a>`, name)
+}
+
+// tocMarker 目录宏的占位标记，整页转换完成后替换为根据标题生成的目录
+const tocMarker = "\x00toc:%d:%d\x00"
+
+var (
+	tocMarkerRe = regexp.MustCompile("\x00toc:(\\d+):(\\d+)\x00")
+	headingRe   = regexp.MustCompile(`^(#{1,6}) (.+)$`)
+)
+
+// renderTOCMacro 输出目录占位标记，minLevel/maxLevel 参数限制目录包含的标题级别
+func renderTOCMacro(params map[string]string) string {
+	minLevel, maxLevel := 1, 6
+	if v, err := strconv.Atoi(params["minLevel"]); err == nil && v >= 1 {
+		minLevel = v
+	}
+	if v, err := strconv.Atoi(params["maxLevel"]); err == nil && v <= 6 && v >= minLevel {
+		maxLevel = v
+	}
+	return fmt.Sprintf(tocMarker, minLevel, maxLevel)
+}
+
+// expandTOC 用转换后的Markdown标题生成目录，替换目录占位标记
+func expandTOC(markdown string) string {
+	if !strings.Contains(markdown, "\x00toc:") {
+		return markdown
+	}
+
+	type heading struct {
+		level int
+		text  string
+	}
+	var headings []heading
+	inFence := false
+	for _, line := range strings.Split(markdown, "\n") {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if match := headingRe.FindStringSubmatch(line); match != nil {
+			headings = append(headings, heading{level: len(match[1]), text: match[2]})
+		}
+	}
+
+	return tocMarkerRe.ReplaceAllStringFunc(markdown, func(marker string) string {
+		match := tocMarkerRe.FindStringSubmatch(marker)
+		minLevel, _ := strconv.Atoi(match[1])
+		maxLevel, _ := strconv.Atoi(match[2])
+
+		// 以实际出现的最高级标题作为目录第一层
+		top := 7
+		for _, h := range headings {
+			if h.level >= minLevel && h.level <= maxLevel && h.level < top {
+				top = h.level
+			}
+		}
+
+		var lines []string
+		slugs := make(map[string]int)
+		for _, h := range headings {
+			slug := headingSlug(h.text)
+			if count := slugs[slug]; count > 0 {
+				slugs[slug]++
+				slug = fmt.Sprintf("%s-%d", slug, count)
+			} else {
+				slugs[slug] = 1
+			}
+			if h.level < minLevel || h.level > maxLevel {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s- [%s](#%s)", strings.Repeat("  ", h.level-top), h.text, slug))
+		}
+		return strings.Join(lines, "\n")
+	})
+}
+
+// headingSlug 按GitHub规则生成标题锚点：转小写，去除标点，空格替换为连字符
+func headingSlug(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
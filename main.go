@@ -37,16 +37,23 @@ func main() {
 	log.Println("")
 	log.Println("Multi-user support enabled - pass credentials via headers:")
 	log.Println("- X-Confluence-Base-URL: Confluence Address")
-	log.Println("- X-Confluence-Name: UserName")
-	log.Println("- X-Confluence-Token: UserPassword")
+	log.Println("- X-Confluence-Name: UserName (not needed for bearer tokens)")
+	log.Println("- X-Confluence-Token: UserPassword, API token or personal access token")
+	log.Println("- X-Confluence-Auth-Type: basic, bearer or anonymous (optional, inferred from the credentials)")
+	log.Println("- X-Jira-Base-URL: Jira Address (optional, for expanding Jira macros)")
+	log.Println("- X-Jira-Authorization: Jira Authorization header value (optional)")
 	log.Println("")
 	log.Println("Available tools:")
 	log.Println("- get_page: 获取Confluence页面并返回Markdown格式（包含页面内容和评论）")
 	log.Println("- get_child_pages: 获取指定页面的子页面列表")
 	log.Println("- create_page: 在Confluence中创建新页面")
+	log.Println("- update_page: 更新Confluence页面（基于版本号的乐观锁）")
+	log.Println("- patch_page_section: 修改页面中指定标题下的章节")
 	log.Println("- create_comment: 为Confluence页面添加评论")
 	log.Println("- search_pages: 在Confluence中搜索页面")
 	log.Println("- convert_page_to_markdown: 将Confluence页面转换为Markdown格式（返回JSON格式的元数据）")
+	log.Println("- list_tasks: 列出页面或CQL搜索结果中的任务")
+	log.Println("- get_page_properties: 获取页面属性（details宏）的键值数据")
 
 	// 启动服务器
 	if err := httpServer.Start(":8080"); err != nil {
@@ -60,6 +67,11 @@ func registerTools(s *server.MCPServer) {
 	s.AddTool(mcp.NewTool("get_page_and_comment",
 		mcp.WithDescription("获取Confluence页面内容并返回Markdown格式（包含页面元数据、内容和评论）"),
 		mcp.WithString("page_id", mcp.Required(), mcp.Description("Confluence页面的ID")),
+		mcp.WithBoolean("include_attachments", mcp.Description("是否在末尾附加附件列表（名称、类型、大小、下载地址）")),
+		mcp.WithBoolean("column_separators", mcp.Description("多列布局中是否以 <!-- column N --> 注释分隔各列")),
+		mcp.WithBoolean("expand_jira", mcp.Description("是否内联Jira问题的摘要和状态（需要配置 X-Jira-Base-URL）")),
+		mcp.WithBoolean("expand_includes", mcp.Description("是否内联 include/excerpt-include 引用的页面内容并展开 children/pagetree 子页面列表")),
+		mcp.WithNumber("include_depth", mcp.Description("嵌入页面的最大深度（默认3）")),
 	), handleGetPage())
 
 	// 获取子页面工具
@@ -74,16 +86,45 @@ func registerTools(s *server.MCPServer) {
 	s.AddTool(mcp.NewTool("create_new_page",
 		mcp.WithDescription("在Confluence中创建新页面"),
 		mcp.WithString("title", mcp.Required(), mcp.Description("页面标题")),
-		mcp.WithString("content", mcp.Required(), mcp.Description("页面内容（支持Confluence存储格式）")),
+		mcp.WithString("content", mcp.Required(), mcp.Description("页面内容，格式由 content_format 指定")),
+		mcp.WithString("content_format", mcp.Description("内容格式：markdown、storage（Confluence存储格式XHTML，默认）或 wiki"), mcp.Enum("markdown", "storage", "wiki")),
 		mcp.WithString("space_key", mcp.Required(), mcp.Description("空间键")),
 		mcp.WithString("parent_id", mcp.Description("父页面ID（可选）")),
+		mcp.WithBoolean("dry_run", mcp.Description("只预览不提交：校验并转换内容，返回最终存储格式和将要创建的内容差异")),
 	), handleCreatePage())
 
+	// 更新页面工具
+	s.AddTool(mcp.NewTool("update_page",
+		mcp.WithDescription("更新Confluence页面的标题和/或内容。需要提供读取页面时的版本号，页面在此之后被修改时返回冲突错误"),
+		mcp.WithString("page_id", mcp.Required(), mcp.Description("页面ID")),
+		mcp.WithNumber("version", mcp.Required(), mcp.Description("读取页面时的版本号（get_page_and_comment 返回的版本）")),
+		mcp.WithString("title", mcp.Description("新标题（可选，默认保持不变）")),
+		mcp.WithString("content", mcp.Description("新内容（可选，默认保持不变），格式由 content_format 指定")),
+		mcp.WithString("content_format", mcp.Description("内容格式：markdown、storage（Confluence存储格式XHTML，默认）或 wiki"), mcp.Enum("markdown", "storage", "wiki")),
+		mcp.WithString("version_message", mcp.Description("版本说明（可选）")),
+		mcp.WithBoolean("dry_run", mcp.Description("只预览不提交：校验并转换内容，返回最终存储格式和相对当前版本的差异")),
+	), handleUpdatePage())
+
+	// 章节修改工具
+	s.AddTool(mcp.NewTool("patch_page_section",
+		mcp.WithDescription("修改页面中某个标题下的章节，页面其余部分保持不变。需要提供读取页面时的版本号"),
+		mcp.WithString("page_id", mcp.Required(), mcp.Description("页面ID")),
+		mcp.WithString("heading", mcp.Required(), mcp.Description("标题文本或标题路径，如 \"Design > API\"（忽略大小写，标题重名时需使用路径区分）")),
+		mcp.WithString("content", mcp.Required(), mcp.Description("新内容，格式由 content_format 指定")),
+		mcp.WithNumber("version", mcp.Required(), mcp.Description("读取页面时的版本号")),
+		mcp.WithString("mode", mcp.Description("修改方式：replace 替换章节正文（保留标题，默认）、append 追加到章节末尾、insert_before 插入到标题之前"), mcp.Enum("replace", "append", "insert_before")),
+		mcp.WithString("content_format", mcp.Description("内容格式：markdown、storage（Confluence存储格式XHTML，默认）或 wiki"), mcp.Enum("markdown", "storage", "wiki")),
+		mcp.WithString("version_message", mcp.Description("版本说明（可选）")),
+		mcp.WithBoolean("dry_run", mcp.Description("只预览不提交：校验并转换内容，返回最终存储格式和相对当前版本的差异")),
+	), handlePatchPageSection())
+
 	// 创建评论工具
 	s.AddTool(mcp.NewTool("create_new_comment",
 		mcp.WithDescription("为Confluence页面添加评论"),
 		mcp.WithString("page_id", mcp.Required(), mcp.Description("页面ID")),
-		mcp.WithString("comment", mcp.Required(), mcp.Description("评论内容")),
+		mcp.WithString("comment", mcp.Required(), mcp.Description("评论内容，格式由 content_format 指定")),
+		mcp.WithString("content_format", mcp.Description("内容格式：markdown、storage（Confluence存储格式XHTML，默认）或 wiki"), mcp.Enum("markdown", "storage", "wiki")),
+		mcp.WithBoolean("dry_run", mcp.Description("只预览不提交：校验并转换内容，返回最终存储格式和将要创建的内容差异")),
 	), handleCreateComment())
 
 	// 搜索页面工具
@@ -99,5 +140,31 @@ func registerTools(s *server.MCPServer) {
 	s.AddTool(mcp.NewTool("convert_page_to_markdown",
 		mcp.WithDescription("将Confluence页面内容转换为Markdown格式，包含页面元数据、内容和评论"),
 		mcp.WithString("page_id", mcp.Required(), mcp.Description("要转换的Confluence页面ID")),
+		mcp.WithBoolean("include_attachments", mcp.Description("是否在末尾附加附件列表（名称、类型、大小、下载地址）")),
+		mcp.WithBoolean("column_separators", mcp.Description("多列布局中是否以 <!-- column N --> 注释分隔各列")),
+		mcp.WithBoolean("expand_jira", mcp.Description("是否内联Jira问题的摘要和状态（需要配置 X-Jira-Base-URL）")),
+		mcp.WithBoolean("expand_includes", mcp.Description("是否内联 include/excerpt-include 引用的页面内容并展开 children/pagetree 子页面列表")),
+		mcp.WithNumber("include_depth", mcp.Description("嵌入页面的最大深度（默认3）")),
 	), handleConvertPageToMarkdown())
+
+	// 任务列表工具
+	s.AddTool(mcp.NewTool("list_tasks",
+		mcp.WithDescription("列出Confluence页面中的任务（ac:task），可指定单个页面或CQL查询（例如 label = \"meeting-notes\" and created >= now(\"-1w\")）"),
+		mcp.WithString("page_id", mcp.Description("页面ID（与cql二选一）")),
+		mcp.WithString("cql", mcp.Description("CQL查询语句（与page_id二选一）")),
+		mcp.WithString("status", mcp.Description("任务状态过滤：incomplete、complete 或 all（默认）")),
+		mcp.WithString("assignee", mcp.Description("按负责人过滤（可选）")),
+		mcp.WithNumber("limit", mcp.Description("CQL查询返回页面的最大数量")),
+	), handleListTasks())
+
+	// 页面属性工具
+	s.AddTool(mcp.NewTool("get_page_properties",
+		mcp.WithDescription("读取页面属性（Page Properties / details宏）表格中的键值对并返回JSON；指定cql或label时返回所有匹配页面的属性，类似页面属性报表"),
+		mcp.WithString("page_id", mcp.Description("页面ID（与cql、label三选一）")),
+		mcp.WithString("cql", mcp.Description("CQL查询语句")),
+		mcp.WithString("label", mcp.Description("按标签查询页面")),
+		mcp.WithString("space_key", mcp.Description("按标签查询时限制的空间（可选）")),
+		mcp.WithString("details_id", mcp.Description("只读取指定id的details宏（可选）")),
+		mcp.WithNumber("limit", mcp.Description("查询返回页面的最大数量")),
+	), handleGetPageProperties())
 }
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// markdownConverter 基于节点树的存储格式到Markdown转换器
+type markdownConverter struct {
+	ctx         context.Context // 工具调用的上下文，转换过程中的所有API请求随之取消
+	client      *ConfluenceClient
+	opts        MarkdownOptions
+	pageID      string                      // 当前转换页面的ID，用于解析附件和相对链接
+	spaceKey    string                      // 当前转换页面所在空间
+	users       map[string]string           // 用户标识到显示名的缓存
+	pages       map[string]*PageResponse    // 空间/标题到页面的缓存，未找到时为 nil
+	attachments map[string][]AttachmentInfo // 页面ID到附件列表的缓存
+	jiraIssues  map[string]*JiraIssue       // Jira问题缓存，查询失败时为 nil
+
+	includeStack      []string       // 正在嵌入的上层页面ID，用于深度限制和循环检测
+	unsupportedMacros map[string]int // 未支持的宏名称及出现次数
+}
+
+// newMarkdownConverter 创建转换器
+func (c *ConfluenceClient) newMarkdownConverter(ctx context.Context) *markdownConverter {
+	return &markdownConverter{
+		ctx:         ctx,
+		client:      c,
+		users:       make(map[string]string),
+		pages:       make(map[string]*PageResponse),
+		attachments: make(map[string][]AttachmentInfo),
+		jiraIssues:  make(map[string]*JiraIssue),
+
+		unsupportedMacros: make(map[string]int),
+	}
+}
+
+// setPage 设置当前转换的页面
+func (m *markdownConverter) setPage(page *PageResponse) {
+	m.pageID = page.ID
+	m.spaceKey = page.Space.Key
+}
+
+// blockElements 块级元素
+var blockElements = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "table": true, "pre": true, "blockquote": true, "hr": true,
+	"section": true, "ac:structured-macro": true, "ac:rich-text-body": true, "ac:task-list": true,
+	"ac:adf-extension": true, "ac:layout": true, "ac:layout-section": true, "ac:layout-cell": true,
+}
+
+// inlineElements 行内元素
+var inlineElements = map[string]bool{
+	"a": true, "strong": true, "b": true, "em": true, "i": true, "code": true, "span": true, "br": true,
+	"ac:link": true, "ac:plain-text-link-body": true, "ac:link-body": true, "ri:user": true, "time": true,
+	"ac:image": true, "img": true, "ac:emoticon": true, "ac:placeholder": true, "s": true, "del": true, "strike": true, "u": true, "sup": true, "sub": true,
+}
+
+// skippedElements 不包含正文内容的元数据元素
+var skippedElements = map[string]bool{
+	"ac:parameter": true, "ac:task-id": true, "ac:task-uuid": true, "ac:task-status": true,
+	"ac:adf-attribute": true,
+}
+
+var (
+	whitespaceRe = regexp.MustCompile(`\s+`)
+	fenceLineRe  = regexp.MustCompile("^[ \t]*(`{3,}|~{3,})")
+	lineStartRe  = regexp.MustCompile(`^(\s*)([#>+-]|\d+[.)])(\s|$)`)
+)
+
+// convert 将存储格式内容转换为Markdown
+func (m *markdownConverter) convert(storage string) string {
+	root := parseStorage(strings.TrimSpace(storage))
+	return cleanupMarkdown(expandTOC(m.renderBlocks(root.Children)))
+}
+
+// isBlock 判断节点是否按块级处理
+// 未知元素只要包含块级后代就视为块级容器
+func (m *markdownConverter) isBlock(n *storageNode) bool {
+	if n.Type == textNode {
+		return false
+	}
+	if n.Name == "ac:structured-macro" {
+		return !isInlineMacro(n)
+	}
+	if blockElements[n.Name] {
+		return true
+	}
+	if inlineElements[n.Name] || skippedElements[n.Name] {
+		return false
+	}
+	for _, c := range n.Children {
+		if m.isBlock(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// markdownBlock 渲染后的块
+type markdownBlock struct {
+	text string
+	list bool
+}
+
+// renderBlocks 渲染一组节点，连续的行内节点合并为一个段落，块之间以空行分隔
+func (m *markdownConverter) renderBlocks(nodes []*storageNode) string {
+	var texts []string
+	for _, block := range m.renderBlockList(nodes) {
+		texts = append(texts, block.text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// renderBlockList 渲染一组节点为块列表
+func (m *markdownConverter) renderBlockList(nodes []*storageNode) []markdownBlock {
+	var blocks []markdownBlock
+	var inline strings.Builder
+
+	flush := func() {
+		if text := strings.TrimSpace(inline.String()); text != "" {
+			blocks = append(blocks, markdownBlock{text: escapeLineStarts(text)})
+		}
+		inline.Reset()
+	}
+
+	for _, n := range nodes {
+		if !m.isBlock(n) {
+			inline.WriteString(m.renderInline(n))
+			continue
+		}
+		flush()
+		if block := strings.Trim(m.renderBlock(n), "\n"); strings.TrimSpace(block) != "" {
+			blocks = append(blocks, markdownBlock{text: block, list: n.Name == "ul" || n.Name == "ol"})
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// renderBlock 渲染块级元素
+func (m *markdownConverter) renderBlock(n *storageNode) string {
+	switch n.Name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Name[1] - '0')
+		text := strings.TrimSpace(strings.ReplaceAll(m.renderInlineChildren(n), "\n", " "))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+	case "ul", "ol":
+		return m.renderList(n)
+	case "table":
+		return m.renderTable(n)
+	case "pre":
+		return fencedCode("", n.textContent())
+	case "hr":
+		return "---"
+	case "blockquote":
+		if content := m.renderBlocks(n.Children); content != "" {
+			return quoteLines(content)
+		}
+		return ""
+	case "ac:structured-macro":
+		return m.renderMacro(n)
+	case "ac:task-list":
+		return m.renderTaskList(n)
+	case "ac:adf-extension":
+		return m.renderADFExtension(n)
+	case "ac:layout-section":
+		return m.renderLayoutSection(n)
+	default:
+		return m.renderBlocks(n.Children)
+	}
+}
+
+// renderList 渲染列表，支持嵌套、有序/无序混排以及 ol 的 start 属性
+// 直接嵌套在列表中（未包裹在 li 内）的子列表归入前一个列表项
+func (m *markdownConverter) renderList(n *storageNode) string {
+	number := 1
+	if start, err := strconv.Atoi(strings.TrimSpace(n.attr("start"))); err == nil {
+		number = start
+	}
+
+	var items []string
+	for _, c := range n.Children {
+		if c.Type != elementNode {
+			continue
+		}
+		switch c.Name {
+		case "li":
+			marker := "-"
+			if n.Name == "ol" {
+				marker = fmt.Sprintf("%d.", number)
+				number++
+			}
+			line := marker
+			if content := m.renderListItem(c); content != "" {
+				line += " " + indentLines(content, strings.Repeat(" ", len(marker)+1))[len(marker)+1:]
+			}
+			items = append(items, line)
+		case "ul", "ol":
+			nested := m.renderList(c)
+			if nested == "" {
+				continue
+			}
+			if len(items) == 0 {
+				items = append(items, nested)
+				continue
+			}
+			indent := strings.Repeat(" ", strings.Index(items[len(items)-1], " ")+1)
+			items[len(items)-1] += "\n" + indentLines(nested, indent)
+		}
+	}
+	return strings.Join(items, "\n")
+}
+
+// renderListItem 渲染列表项内容，子列表紧跟在文本之后，其余块之间以空行分隔
+func (m *markdownConverter) renderListItem(item *storageNode) string {
+	var sb strings.Builder
+	for i, block := range m.renderBlockList(item.Children) {
+		if i > 0 {
+			if block.list {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(block.text)
+	}
+	return sb.String()
+}
+
+// isInlineMacro 判断宏是否在段落中按行内内容渲染
+func isInlineMacro(n *storageNode) bool {
+	switch n.macroName() {
+	case "status", "anchor":
+		return true
+	case "jira":
+		return n.macroParams()["key"] != ""
+	}
+	return false
+}
+
+// renderMacro 渲染Confluence宏
+func (m *markdownConverter) renderMacro(n *storageNode) string {
+	params := n.macroParams()
+	switch n.macroName() {
+	case "code", "noformat":
+		return m.renderCodeMacro(n, params)
+	case "jira":
+		return m.renderJiraMacro(n)
+	case "status":
+		return renderStatusMacro(params)
+	case "anchor":
+		return renderAnchorMacro(params)
+	case "toc":
+		return renderTOCMacro(params)
+	case "include", "excerpt-include":
+		return m.renderIncludeMacro(n)
+	case "children", "pagetree":
+		return m.renderChildrenMacro(n)
+	case "excerpt", "details":
+		if body := n.child("ac:rich-text-body"); body != nil {
+			return m.renderBlocks(body.Children)
+		}
+		return ""
+	case "info", "note", "tip", "success", "warning", "error":
+		return m.renderCallout(calloutTypes[n.macroName()], params["title"], n.child("ac:rich-text-body"))
+	case "panel":
+		return m.renderCallout("", params["title"], n.child("ac:rich-text-body"))
+	case "expand":
+		title := params["title"]
+		if title == "" {
+			title = "展开"
+		}
+		return m.renderCallout("", "▸ "+title, n.child("ac:rich-text-body"))
+	default:
+		m.unsupportedMacros[n.macroName()]++
+		return m.renderMacroPlaceholder(n, params)
+	}
+}
+
+// renderMacroPlaceholder 为未支持的宏输出可见的占位标记，如 [macro: drawio name=arch.drawio]
+// 宏带有富文本正文时继续转换正文，带有纯文本正文时以代码块输出
+func (m *markdownConverter) renderMacroPlaceholder(n *storageNode, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{"macro: " + n.macroName()}
+	for _, key := range keys {
+		value := params[key]
+		if strings.ContainsAny(value, " \t\n]") {
+			value = strconv.Quote(value)
+		}
+		if key == "" {
+			parts = append(parts, value)
+		} else {
+			parts = append(parts, key+"="+value)
+		}
+	}
+
+	blocks := []string{"[" + strings.Join(parts, " ") + "]"}
+	if body := n.child("ac:rich-text-body"); body != nil {
+		if content := m.renderBlocks(body.Children); content != "" {
+			blocks = append(blocks, content)
+		}
+	} else if body := n.child("ac:plain-text-body"); body != nil {
+		if code := strings.TrimSpace(body.textContent()); code != "" {
+			blocks = append(blocks, fencedCode("", body.textContent()))
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// renderLayoutSection 按阅读顺序渲染布局分区中的各列
+// 多列分区在启用 ColumnSeparators 时以 <!-- column N --> 注释分隔各列
+func (m *markdownConverter) renderLayoutSection(n *storageNode) string {
+	cells := n.childrenNamed("ac:layout-cell")
+	var parts []string
+	for i, cell := range cells {
+		content := m.renderBlocks(cell.Children)
+		if m.opts.ColumnSeparators && len(cells) > 1 {
+			parts = append(parts, fmt.Sprintf("<!-- column %d -->", i+1))
+		}
+		if content != "" {
+			parts = append(parts, content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// calloutTypes Confluence面板类型到GFM提示块类型的映射
+// Confluence 的 note 为黄色提醒，warning 为红色警告，error 为错误
+var calloutTypes = map[string]string{
+	"info":    "NOTE",
+	"note":    "IMPORTANT",
+	"tip":     "TIP",
+	"success": "TIP",
+	"warning": "WARNING",
+	"error":   "CAUTION",
+}
+
+// renderCallout 渲染面板类宏为引用块，正文递归转换
+// kind 不为空时输出GFM提示块标记（> [!NOTE]），title 不为空时输出粗体标题
+func (m *markdownConverter) renderCallout(kind, title string, body *storageNode) string {
+	var parts []string
+	if kind != "" {
+		parts = append(parts, "[!"+kind+"]")
+	}
+	if title != "" {
+		parts = append(parts, "**"+title+"**")
+	}
+	if body != nil {
+		if content := m.renderBlocks(body.Children); content != "" {
+			if title != "" {
+				content = "\n" + content
+			}
+			parts = append(parts, content)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return quoteLines(strings.Join(parts, "\n"))
+}
+
+// renderADFExtension 渲染新版编辑器的 ac:adf-extension，面板节点按提示块处理，其余使用回退内容
+func (m *markdownConverter) renderADFExtension(n *storageNode) string {
+	if node := n.child("ac:adf-node"); node != nil && node.attr("type") == "panel" {
+		panelType := ""
+		for _, attr := range node.childrenNamed("ac:adf-attribute") {
+			if attr.attr("key") == "panel-type" {
+				panelType = strings.TrimSpace(attr.textContent())
+			}
+		}
+		return m.renderCallout(calloutTypes[panelType], "", node.child("ac:adf-content"))
+	}
+	if fallback := n.child("ac:adf-fallback"); fallback != nil {
+		return m.renderBlocks(fallback.Children)
+	}
+	return ""
+}
+
+// quoteLines 为每行添加引用前缀
+func quoteLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderInlineChildren 以行内方式渲染所有子节点
+func (m *markdownConverter) renderInlineChildren(n *storageNode) string {
+	var sb strings.Builder
+	for _, c := range n.Children {
+		sb.WriteString(m.renderInline(c))
+	}
+	return sb.String()
+}
+
+// renderInline 渲染行内节点
+func (m *markdownConverter) renderInline(n *storageNode) string {
+	if n.Type == textNode {
+		// 不换行空格按普通空格处理
+		text := strings.ReplaceAll(n.Text, "\u00a0", " ")
+		return escapeMarkdown(whitespaceRe.ReplaceAllString(text, " "))
+	}
+	if skippedElements[n.Name] {
+		return ""
+	}
+
+	switch n.Name {
+	case "strong", "b":
+		return wrapInline(m.renderInlineChildren(n), "**")
+	case "em", "i":
+		return wrapInline(m.renderInlineChildren(n), "*")
+	case "s", "del", "strike":
+		return wrapInline(m.renderInlineChildren(n), "~~")
+	case "u", "sup", "sub":
+		// Markdown没有对应语法，保留为HTML标签
+		return "<" + n.Name + ">" + m.renderInlineChildren(n) + "</" + n.Name + ">"
+	case "code":
+		return inlineCode(n.textContent())
+	case "br":
+		return "  \n"
+	case "ac:link":
+		return m.renderLink(n)
+	case "ac:structured-macro":
+		return m.renderMacro(n)
+	case "ac:image":
+		return m.renderImage(n)
+	case "ac:emoticon":
+		return renderEmoticon(n)
+	case "ac:placeholder":
+		if text := strings.TrimSpace(n.textContent()); text != "" {
+			return "<!-- " + text + " -->"
+		}
+		return ""
+	case "img":
+		if src := n.attr("src"); src != "" {
+			return fmt.Sprintf("![%s](%s)", n.attr("alt"), linkDestination(src))
+		}
+		return ""
+	case "ri:user":
+		if name := m.userName(n); name != "" {
+			return "@" + name
+		}
+		return ""
+	case "time":
+		if datetime := n.attr("datetime"); datetime != "" {
+			return formatDateTime(datetime)
+		}
+		return m.renderInlineChildren(n)
+	case "span":
+		// 颜色等样式无法在Markdown中表达，只保留文本
+		return m.renderInlineChildren(n)
+	case "a":
+		text := strings.TrimSpace(m.renderInlineChildren(n))
+		href := n.attr("href")
+		if href == "" {
+			return text
+		}
+		if text == "" {
+			text = href
+		}
+		return fmt.Sprintf("[%s](%s)", text, linkDestination(href))
+	default:
+		return m.renderInlineChildren(n)
+	}
+}
+
+// userAttrParams ri:user 属性与用户接口查询参数的对应关系
+var userAttrParams = []struct {
+	attr  string
+	param string
+}{
+	{"ri:account-id", "accountId"},
+	{"ri:userkey", "key"},
+	{"ri:username", "username"},
+}
+
+// userName 返回 ri:user 引用用户的显示名
+// 通过用户接口查询并缓存，查询失败时退回用户标识
+func (m *markdownConverter) userName(n *storageNode) string {
+	for _, p := range userAttrParams {
+		value := n.attr(p.attr)
+		if value == "" {
+			continue
+		}
+
+		cacheKey := p.param + ":" + value
+		if name, ok := m.users[cacheKey]; ok {
+			return name
+		}
+
+		name := value
+		if m.client.supportsUserLookup(p.param) {
+			if user, err := m.client.GetUser(m.ctx, p.param, value); err == nil && user.DisplayName != "" {
+				name = user.DisplayName
+			}
+		}
+		m.users[cacheKey] = name
+		return name
+	}
+	return ""
+}
+
+// markdownEscaper 转义文本中会被误解析为Markdown格式的字符
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"~", `\~`,
+)
+
+// escapeMarkdown 转义普通文本中的Markdown特殊字符
+// 单词内部的下划线（如 snake_case）不会形成强调，不做转义；尖括号仅在看起来像HTML标签时转义
+func escapeMarkdown(text string) string {
+	text = markdownEscaper.Replace(text)
+
+	var sb strings.Builder
+	runes := []rune(text)
+	for i, r := range runes {
+		switch r {
+		case '_':
+			if i == 0 || i == len(runes)-1 || !isWordRune(runes[i-1]) || !isWordRune(runes[i+1]) {
+				sb.WriteRune('\\')
+			}
+		case '<':
+			if i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '/' || runes[i+1] == '!') {
+				sb.WriteRune('\\')
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// isWordRune 判断字符是否为字母或数字
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// escapeLineStarts 转义段落中位于行首、会被解析为标题、引用或列表的字符
+func escapeLineStarts(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if loc := lineStartRe.FindStringSubmatchIndex(line); loc != nil {
+			marker := loc[5] - 1
+			lines[i] = line[:marker] + `\` + line[marker:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatDateTime 将 time 元素的 datetime 属性格式化为ISO日期（带时间时保留时间）
+func formatDateTime(datetime string) string {
+	datetime = strings.TrimSpace(datetime)
+	if t, err := time.Parse("2006-01-02", datetime); err == nil {
+		return t.Format("2006-01-02")
+	}
+	if t, err := time.Parse(time.RFC3339, datetime); err == nil {
+		return t.Format(time.RFC3339)
+	}
+	return datetime
+}
+
+// wrapInline 用标记包裹行内文本，首尾空白移到标记外侧
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:len(text)-len(strings.TrimLeft(text, " \t\n"))]
+	trailing := text[len(strings.TrimRight(text, " \t\n")):]
+	return leading + marker + trimmed + marker + trailing
+}
+
+// cleanupMarkdown 清理Markdown格式：清空只含空白的行并合并连续空行
+// 围栏代码块内的内容原样保留
+func cleanupMarkdown(content string) string {
+	var result []string
+	fence := ""
+	blank := false
+	for _, line := range strings.Split(content, "\n") {
+		if fence != "" {
+			result = append(result, line)
+			if m := fenceLineRe.FindStringSubmatch(line); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(m[0]):]) == "" {
+				fence = ""
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			if !blank {
+				result = append(result, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		if m := fenceLineRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+		}
+		result = append(result, line)
+	}
+
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
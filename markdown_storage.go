@@ -0,0 +1,624 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	mdHeadingRe    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdFenceRe      = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	mdRuleRe       = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	mdListItemRe   = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +|$)(.*)$`)
+	mdTaskRe       = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)(.*)$`)
+	mdTableDelimRe = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdCalloutRe    = regexp.MustCompile(`^\[!(NOTE|TIP|IMPORTANT|WARNING|CAUTION)\][ \t]*$`)
+	mdCalloutTitle = regexp.MustCompile(`^\*\*(.+)\*\*$`)
+)
+
+// calloutMacros GFM提示块类型到Confluence面板宏的映射
+var calloutMacros = map[string]string{
+	"NOTE":      "info",
+	"TIP":       "tip",
+	"IMPORTANT": "note",
+	"WARNING":   "warning",
+	"CAUTION":   "warning",
+}
+
+// storageLanguages 代码块信息字符串到Confluence代码宏语言名的映射，未列出的原样使用
+var storageLanguages = map[string]string{
+	"csharp":    "c#",
+	"cs":        "c#",
+	"sh":        "bash",
+	"shell":     "bash",
+	"zsh":       "bash",
+	"golang":    "go",
+	"yaml":      "yml",
+	"text":      "",
+	"plaintext": "",
+}
+
+// storageWriter Markdown到Confluence存储格式的转换器
+type storageWriter struct {
+	taskID int // 文档内任务编号
+}
+
+// markdownToStorage 将Markdown（GFM）转换为Confluence存储格式XHTML
+// 围栏代码块转换为 code 宏，> [!NOTE] 提示块转换为信息面板宏，任务列表转换为 ac:task-list
+func markdownToStorage(markdown string) string {
+	w := &storageWriter{}
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+	markdown = strings.ReplaceAll(markdown, "\t", "    ")
+	return strings.Join(w.blocks(strings.Split(markdown, "\n")), "")
+}
+
+// mdBlock 解析出的块
+type mdBlock struct {
+	html      string
+	paragraph string // 段落块的行内内容，用于紧凑列表项去掉 <p> 包裹
+}
+
+// blocks 将若干行解析为存储格式块
+func (w *storageWriter) blocks(lines []string) []string {
+	var result []string
+	for _, b := range w.parseBlocks(lines) {
+		result = append(result, b.html)
+	}
+	return result
+}
+
+// parseBlocks 逐行解析块级结构
+func (w *storageWriter) parseBlocks(lines []string) []mdBlock {
+	var blocks []mdBlock
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		inline := w.inline(strings.TrimSpace(strings.Join(paragraph, "\n")))
+		blocks = append(blocks, mdBlock{html: "<p>" + inline + "</p>", paragraph: inline})
+		paragraph = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if m := mdFenceRe.FindStringSubmatch(line); m != nil {
+			flush()
+			end := len(lines)
+			var code []string
+			for j := i + 1; j < len(lines); j++ {
+				closing := strings.TrimSpace(lines[j])
+				if strings.HasPrefix(closing, m[2]) && strings.Trim(closing, m[2][:1]) == "" {
+					end = j
+					break
+				}
+				code = append(code, strings.TrimPrefix(lines[j], m[1]))
+			}
+			blocks = append(blocks, mdBlock{html: codeMacro(strings.Fields(m[3]), strings.Join(code, "\n"))})
+			i = end
+			continue
+		}
+
+		if m := mdHeadingRe.FindStringSubmatch(line); m != nil {
+			flush()
+			level := len(m[1])
+			blocks = append(blocks, mdBlock{html: fmt.Sprintf("<h%d>%s</h%d>", level, w.inline(strings.TrimSpace(m[2])), level)})
+			continue
+		}
+
+		if mdRuleRe.MatchString(line) {
+			flush()
+			blocks = append(blocks, mdBlock{html: "<hr/>"})
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimLeft(line, " "), ">") {
+			flush()
+			var quoted []string
+			for ; i < len(lines); i++ {
+				trimmed := strings.TrimLeft(lines[i], " ")
+				if !strings.HasPrefix(trimmed, ">") {
+					break
+				}
+				trimmed = strings.TrimPrefix(trimmed, ">")
+				quoted = append(quoted, strings.TrimPrefix(trimmed, " "))
+			}
+			i--
+			blocks = append(blocks, mdBlock{html: w.blockquote(quoted)})
+			continue
+		}
+
+		if mdListItemRe.MatchString(line) && (len(paragraph) == 0 || startsList(line)) {
+			flush()
+			html, next := w.list(lines, i)
+			blocks = append(blocks, mdBlock{html: html})
+			i = next - 1
+			continue
+		}
+
+		if strings.Contains(line, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") && mdTableDelimRe.MatchString(lines[i+1]) && len(paragraph) == 0 {
+			html, next := w.table(lines, i)
+			blocks = append(blocks, mdBlock{html: html})
+			i = next - 1
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+	flush()
+
+	return blocks
+}
+
+// startsList 判断列表项能否打断段落：与CommonMark一致，有序列表只有从1开始才能打断段落
+func startsList(line string) bool {
+	m := mdListItemRe.FindStringSubmatch(line)
+	if m == nil || strings.TrimSpace(m[4]) == "" {
+		return false
+	}
+	if n, err := strconv.Atoi(strings.TrimRight(m[2], ".)")); err == nil {
+		return n == 1
+	}
+	return true
+}
+
+// blockquote 渲染引用块，首行为 [!TYPE] 时转换为对应的面板宏
+func (w *storageWriter) blockquote(lines []string) string {
+	if len(lines) > 0 {
+		if m := mdCalloutRe.FindStringSubmatch(strings.TrimSpace(lines[0])); m != nil {
+			body := lines[1:]
+			title := ""
+			if len(body) > 0 {
+				if t := mdCalloutTitle.FindStringSubmatch(strings.TrimSpace(body[0])); t != nil {
+					title = t[1]
+					body = body[1:]
+				}
+			}
+
+			var sb strings.Builder
+			sb.WriteString(fmt.Sprintf(`<ac:structured-macro ac:name="%s">`, calloutMacros[m[1]]))
+			if title != "" {
+				sb.WriteString(`<ac:parameter ac:name="title">` + escapeXML(title) + `</ac:parameter>`)
+			}
+			sb.WriteString("<ac:rich-text-body>" + strings.Join(w.blocks(body), "") + "</ac:rich-text-body>")
+			sb.WriteString("</ac:structured-macro>")
+			return sb.String()
+		}
+	}
+	return "<blockquote>" + strings.Join(w.blocks(lines), "") + "</blockquote>"
+}
+
+// listItem 解析出的列表项
+type listItem struct {
+	lines []string
+}
+
+// list 解析从第 start 行开始的列表，返回XHTML和列表之后的行号
+// 缩进大于列表标记缩进的行都归入当前列表项，按内容缩进去除前导空格后递归解析
+// 列表项之间或项内块之间有空行时整个列表为松散列表，所有列表项的段落都包裹 <p>
+func (w *storageWriter) list(lines []string, start int) (string, int) {
+	first := mdListItemRe.FindStringSubmatch(lines[start])
+	baseIndent := len(first[1])
+	ordered := first[2] != "-" && first[2] != "*" && first[2] != "+"
+
+	var items []listItem
+	var current *listItem
+	contentIndent := 0
+	blank := false
+	loose := false
+
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			current.lines = append(current.lines, "")
+			continue
+		}
+
+		if m := mdListItemRe.FindStringSubmatch(line); m != nil && indent <= baseIndent+1 {
+			itemOrdered := m[2] != "-" && m[2] != "*" && m[2] != "+"
+			if itemOrdered != ordered {
+				break
+			}
+			if blank && current != nil {
+				loose = true
+			}
+			items = append(items, listItem{})
+			current = &items[len(items)-1]
+			spaces := len(m[3])
+			if spaces > 4 || m[4] == "" {
+				spaces = 1
+			}
+			contentIndent = len(m[1]) + len(m[2]) + spaces
+			current.lines = append(current.lines, m[4])
+			blank = false
+			continue
+		}
+
+		if indent > baseIndent {
+			if blank {
+				loose = true
+			}
+			current.lines = append(current.lines, line[min(indent, contentIndent):])
+			blank = false
+			continue
+		}
+
+		// 惰性续行：紧跟在段落文本后的非块级行
+		if !blank && !mdHeadingRe.MatchString(line) && !mdFenceRe.MatchString(line) && !mdRuleRe.MatchString(line) && !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			current.lines = append(current.lines, strings.TrimSpace(line))
+			continue
+		}
+		break
+	}
+
+	// 列表末尾的空行不属于列表项
+	for idx := range items {
+		for len(items[idx].lines) > 0 && items[idx].lines[len(items[idx].lines)-1] == "" {
+			items[idx].lines = items[idx].lines[:len(items[idx].lines)-1]
+		}
+	}
+
+	if !ordered && allTasks(items) {
+		return w.taskList(items), i
+	}
+
+	var sb strings.Builder
+	if ordered {
+		sb.WriteString("<ol")
+		if n, err := strconv.Atoi(strings.TrimRight(first[2], ".)")); err == nil && n != 1 {
+			sb.WriteString(fmt.Sprintf(` start="%d"`, n))
+		}
+		sb.WriteString(">")
+	} else {
+		sb.WriteString("<ul>")
+	}
+	for _, item := range items {
+		sb.WriteString("<li>" + w.itemContent(item, loose) + "</li>")
+	}
+	if ordered {
+		sb.WriteString("</ol>")
+	} else {
+		sb.WriteString("</ul>")
+	}
+	return sb.String(), i
+}
+
+// itemContent 渲染列表项内容，紧凑列表中列表项的首段落不包裹 <p>
+func (w *storageWriter) itemContent(item listItem, loose bool) string {
+	blocks := w.parseBlocks(item.lines)
+	var sb strings.Builder
+	for i, b := range blocks {
+		if i == 0 && !loose && b.paragraph != "" {
+			sb.WriteString(b.paragraph)
+			continue
+		}
+		sb.WriteString(b.html)
+	}
+	return sb.String()
+}
+
+// allTasks 判断列表项是否全部为任务（以 [ ] 或 [x] 开头）
+func allTasks(items []listItem) bool {
+	for _, item := range items {
+		if len(item.lines) == 0 || !mdTaskRe.MatchString(item.lines[0]) {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+// taskList 渲染任务列表为 ac:task-list
+// 与Confluence编辑器一致，嵌套任务列表作为兄弟元素紧跟在所属任务之后，而不是放在 ac:task-body 中
+func (w *storageWriter) taskList(items []listItem) string {
+	var sb strings.Builder
+	sb.WriteString("<ac:task-list>")
+	for _, item := range items {
+		m := mdTaskRe.FindStringSubmatch(item.lines[0])
+		status := "incomplete"
+		if m[1] != " " {
+			status = "complete"
+		}
+		item.lines[0] = m[2]
+
+		w.taskID++
+		sb.WriteString("<ac:task>")
+		sb.WriteString(fmt.Sprintf("<ac:task-id>%d</ac:task-id>", w.taskID))
+		sb.WriteString("<ac:task-status>" + status + "</ac:task-status>")
+
+		var body, nested strings.Builder
+		for i, b := range w.parseBlocks(item.lines) {
+			switch {
+			case strings.HasPrefix(b.html, "<ac:task-list>"):
+				nested.WriteString(b.html)
+			case i == 0 && b.paragraph != "":
+				body.WriteString(b.paragraph)
+			default:
+				body.WriteString(b.html)
+			}
+		}
+		sb.WriteString("<ac:task-body>" + body.String() + "</ac:task-body>")
+		sb.WriteString("</ac:task>")
+		sb.WriteString(nested.String())
+	}
+	sb.WriteString("</ac:task-list>")
+	return sb.String()
+}
+
+// table 解析GFM表格，返回XHTML和表格之后的行号
+func (w *storageWriter) table(lines []string, start int) (string, int) {
+	var sb strings.Builder
+	sb.WriteString("<table><tbody>")
+
+	sb.WriteString("<tr>")
+	for _, cell := range splitTableRow(lines[start]) {
+		sb.WriteString("<th>" + w.inline(cell) + "</th>")
+	}
+	sb.WriteString("</tr>")
+
+	i := start + 2
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" || !strings.Contains(lines[i], "|") {
+			break
+		}
+		sb.WriteString("<tr>")
+		for _, cell := range splitTableRow(lines[i]) {
+			sb.WriteString("<td>" + w.inline(cell) + "</td>")
+		}
+		sb.WriteString("</tr>")
+	}
+
+	sb.WriteString("</tbody></table>")
+	return sb.String(), i
+}
+
+// splitTableRow 按未转义的竖线拆分表格行
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// codeMacro 生成 code 宏
+func codeMacro(info []string, code string) string {
+	var sb strings.Builder
+	sb.WriteString(`<ac:structured-macro ac:name="code">`)
+	if len(info) > 0 {
+		language := strings.ToLower(info[0])
+		if mapped, ok := storageLanguages[language]; ok {
+			language = mapped
+		}
+		if language != "" {
+			sb.WriteString(`<ac:parameter ac:name="language">` + escapeXML(language) + `</ac:parameter>`)
+		}
+	}
+	// CDATA 中不能出现 ]]>，拆分为两段
+	code = strings.ReplaceAll(code, "]]>", "]]]]><![CDATA[>")
+	sb.WriteString("<ac:plain-text-body><![CDATA[" + code + "]]></ac:plain-text-body>")
+	sb.WriteString("</ac:structured-macro>")
+	return sb.String()
+}
+
+// inline 渲染行内内容：代码、链接、图片、强调、删除线、换行，其余文本做XML转义
+func (w *storageWriter) inline(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			sb.WriteString("<br/>")
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(text) && unicode.IsPunct(rune(text[i+1])) || c == '\\' && i+1 < len(text) && unicode.IsSymbol(rune(text[i+1])):
+			sb.WriteString(escapeXML(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '\n':
+			if strings.HasSuffix(sb.String(), "  ") {
+				trimmed := strings.TrimRight(sb.String(), " ")
+				sb.Reset()
+				sb.WriteString(trimmed + "<br/>")
+			} else {
+				sb.WriteString(" ")
+			}
+			i++
+			continue
+		case c == '`':
+			run := countRun(text[i:], '`')
+			fence := strings.Repeat("`", run)
+			if end := strings.Index(text[i+run:], fence); end >= 0 {
+				code := text[i+run : i+run+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				sb.WriteString("<code>" + escapeXML(code) + "</code>")
+				i += run + end + run
+				continue
+			}
+			sb.WriteString(fence)
+			i += run
+			continue
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			if label, href, _, n := parseMarkdownLink(text[i+1:]); n > 0 {
+				sb.WriteString(fmt.Sprintf(`<ac:image ac:alt="%s"><ri:url ri:value="%s"/></ac:image>`, escapeXML(label), escapeXML(href)))
+				i += 1 + n
+				continue
+			}
+		case c == '[':
+			if label, href, title, n := parseMarkdownLink(text[i:]); n > 0 {
+				sb.WriteString(`<a href="` + escapeXML(href) + `"`)
+				if title != "" {
+					sb.WriteString(` title="` + escapeXML(title) + `"`)
+				}
+				sb.WriteString(">" + w.inline(label) + "</a>")
+				i += n
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				target := text[i+1 : i+end]
+				if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "mailto:") {
+					sb.WriteString(`<a href="` + escapeXML(target) + `">` + escapeXML(target) + "</a>")
+					i += end + 1
+					continue
+				}
+			}
+		case c == '~' && strings.HasPrefix(text[i:], "~~"):
+			if inner, n := delimited(text[i:], "~~"); n > 0 {
+				sb.WriteString("<del>" + w.inline(inner) + "</del>")
+				i += n
+				continue
+			}
+		case c == '*' || c == '_':
+			// 单词内部的下划线不构成强调
+			if prev, _ := utf8.DecodeLastRuneInString(text[:i]); c == '_' && isWordRune(prev) {
+				break
+			}
+			run := countRun(text[i:], c)
+			if run >= 2 {
+				if inner, n := delimited(text[i:], strings.Repeat(string(c), 2)); n > 0 {
+					sb.WriteString("<strong>" + w.inline(inner) + "</strong>")
+					i += n
+					continue
+				}
+			}
+			if inner, n := delimited(text[i:], string(c)); n > 0 {
+				sb.WriteString("<em>" + w.inline(inner) + "</em>")
+				i += n
+				continue
+			}
+		}
+		sb.WriteString(escapeXML(text[i : i+1]))
+		i++
+	}
+	return sb.String()
+}
+
+// countRun 返回字符串开头连续出现指定字符的次数
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// delimited 查找以 marker 开始和结束的片段，返回内部文本和消耗的长度
+// 内部文本不能为空，也不能以空白开头或结尾；下划线的闭合标记后不能紧跟字母或数字
+func delimited(s, marker string) (string, int) {
+	rest := s[len(marker):]
+	for offset := 0; offset < len(rest); {
+		end := strings.Index(rest[offset:], marker)
+		if end < 0 {
+			return "", 0
+		}
+		end += offset
+		run := countRun(rest[end:], marker[0])
+		if len(marker) == 1 && run == 2 {
+			// 单个标记时整体跳过双标记（如 *a **b** c* 中的 **），留给内层的加粗
+			offset = end + run
+			continue
+		}
+		// 更长的标记串（如 **a *b*** 中的 ***）由最后的标记闭合
+		end += run - len(marker)
+		inner := rest[:end]
+		next, _ := utf8.DecodeRuneInString(rest[end+len(marker):])
+		if inner != "" && strings.TrimSpace(inner) == inner && rest[end-1] != '\\' && !(marker[0] == '_' && isWordRune(next)) {
+			return inner, len(marker) + end + len(marker)
+		}
+		offset = end + len(marker)
+	}
+	return "", 0
+}
+
+// parseMarkdownLink 解析 [文本](地址 "标题")，返回文本、地址、标题和消耗的长度，失败时长度为0
+func parseMarkdownLink(s string) (string, string, string, int) {
+	depth := 0
+	closing := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = i
+			}
+		}
+		if closing >= 0 {
+			break
+		}
+	}
+	if closing < 0 || closing+1 >= len(s) || s[closing+1] != '(' {
+		return "", "", "", 0
+	}
+
+	depth = 0
+	end := -1
+	for i := closing + 1; i < len(s); i++ {
+		if s[i] == '(' {
+			depth++
+		} else if s[i] == ')' {
+			depth--
+			if depth == 0 {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return "", "", "", 0
+	}
+
+	target := strings.TrimSpace(s[closing+2 : end])
+	title := ""
+	if idx := strings.Index(target, ` "`); idx >= 0 && strings.HasSuffix(target, `"`) {
+		title = target[idx+2 : len(target)-1]
+		target = strings.TrimSpace(target[:idx])
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+
+	return s[1:closing], target, title, end + 1
+}
+
+// xmlEscaper 转义XML特殊字符
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// escapeXML 转义XML特殊字符
+func escapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
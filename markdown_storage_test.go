@@ -0,0 +1,134 @@
+package main
+
+import "testing"
+
+func TestMarkdownToStorage(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "heading and inline formatting",
+			markdown: "# Title\n\nHello **bold** and *em* and `code` <tag> & co",
+			want:     `<h1>Title</h1><p>Hello <strong>bold</strong> and <em>em</em> and <code>code</code> &lt;tag&gt; &amp; co</p>`,
+		},
+		{
+			name:     "nested emphasis",
+			markdown: "text *a **b** c* end",
+			want:     `<p>text <em>a <strong>b</strong> c</em> end</p>`,
+		},
+		{
+			name:     "emphasis closed by longer run",
+			markdown: "**a *b***",
+			want:     `<p><strong>a <em>b</em></strong></p>`,
+		},
+		{
+			name:     "underscores inside words",
+			markdown: "中文_下划线_测试 snake_case_name _a_b",
+			want:     `<p>中文_下划线_测试 snake_case_name _a_b</p>`,
+		},
+		{
+			name:     "tight list",
+			markdown: "- a\n- b",
+			want:     `<ul><li>a</li><li>b</li></ul>`,
+		},
+		{
+			name:     "loose list applies to every item",
+			markdown: "- a\n\n\n- b",
+			want:     `<ul><li><p>a</p></li><li><p>b</p></li></ul>`,
+		},
+		{
+			name:     "ordered list with start",
+			markdown: "3. three\n4. four",
+			want:     `<ol start="3"><li>three</li><li>four</li></ol>`,
+		},
+		{
+			name:     "nested list",
+			markdown: "- outer\n  - inner\n- next",
+			want:     `<ul><li>outer<ul><li>inner</li></ul></li><li>next</li></ul>`,
+		},
+		{
+			name:     "task list",
+			markdown: "- [ ] todo\n- [x] done",
+			want: `<ac:task-list>` +
+				`<ac:task><ac:task-id>1</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>todo</ac:task-body></ac:task>` +
+				`<ac:task><ac:task-id>2</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>done</ac:task-body></ac:task>` +
+				`</ac:task-list>`,
+		},
+		{
+			name:     "nested task list follows its parent task",
+			markdown: "- [x] a\n  - [ ] b\n- [ ] c",
+			want: `<ac:task-list>` +
+				`<ac:task><ac:task-id>1</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>a</ac:task-body></ac:task>` +
+				`<ac:task-list><ac:task><ac:task-id>2</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>b</ac:task-body></ac:task></ac:task-list>` +
+				`<ac:task><ac:task-id>3</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>c</ac:task-body></ac:task>` +
+				`</ac:task-list>`,
+		},
+		{
+			name:     "fenced code becomes code macro",
+			markdown: "```go\nif a < b && c > d {}\n```",
+			want:     `<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter><ac:plain-text-body><![CDATA[if a < b && c > d {}]]></ac:plain-text-body></ac:structured-macro>`,
+		},
+		{
+			name:     "callout becomes panel macro",
+			markdown: "> [!WARNING]\n> careful",
+			want:     `<ac:structured-macro ac:name="warning"><ac:rich-text-body><p>careful</p></ac:rich-text-body></ac:structured-macro>`,
+		},
+		{
+			name:     "table",
+			markdown: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:     `<table><tbody><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></tbody></table>`,
+		},
+		{
+			name:     "link and image",
+			markdown: "[link](https://example.com?a=1&b=2) and ![img](pic.png)",
+			want:     `<p><a href="https://example.com?a=1&amp;b=2">link</a> and <ac:image ac:alt="img"><ri:url ri:value="pic.png"/></ac:image></p>`,
+		},
+		{
+			name:     "strikethrough and escapes",
+			markdown: "~~strike~~ and a\\*literal",
+			want:     `<p><del>strike</del> and a*literal</p>`,
+		},
+		{
+			name:     "horizontal rule",
+			markdown: "---",
+			want:     `<hr/>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markdownToStorage(tt.markdown)
+			if got != tt.want {
+				t.Errorf("markdownToStorage()\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToStorageIsValid(t *testing.T) {
+	inputs := []string{
+		"# Title\n\nText with <html> & \"quotes\" and 'apostrophes'",
+		"- a\n\n  continued\n- b\n  - nested\n\n    deeper",
+		"1. one\n2. two\n\n   para\n3. three",
+		"- [ ] todo with **bold**\n- [x] done\n  - [ ] nested task",
+		"```\ncode with ]]> inside\n```",
+		"~~~xml\n<root attr=\"1\"/>\n~~~",
+		"> [!NOTE]\n> **Heads up**\n> body with `code`",
+		"> quote\n>\n> - list in quote",
+		"| a | b |\n|:-|-:|\n| `x|y` | [l](http://e.com) |\n| 1 |",
+		"![alt](https://example.com/a.png \"title\") and <https://example.com>",
+		"Line with trailing spaces  \nnext line\\\nlast",
+		"***bold italic*** _under_ __strong__ `a & b` \\<not tag\\>",
+		"中文段落，包含 **粗体** 和 [链接](https://example.com/路径?q=值)",
+		"* * *\n\n***\n\nSetext is not supported\n===",
+	}
+
+	for _, markdown := range inputs {
+		storage := markdownToStorage(markdown)
+		if err := validateStorage(storage); err != nil {
+			t.Errorf("markdownToStorage(%q) produced invalid storage: %v\n%s", markdown, err, storage)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import "testing"
+
+func TestStorageToMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage string
+		want    string
+	}{
+		{
+			name:    "heading, line break and entities",
+			storage: `<h2>Title &amp; more</h2><p>a<br/>b</p>`,
+			want:    "## Title & more\n\na  \nb",
+		},
+		{
+			name:    "nested list",
+			storage: `<ul><li>a</li><li>b<ul><li>c</li></ul></li></ul>`,
+			want:    "- a\n- b\n  - c",
+		},
+		{
+			name:    "ordered list keeps start number",
+			storage: `<ol start="3"><li>three</li><li>four</li></ol>`,
+			want:    "3. three\n4. four",
+		},
+		{
+			name:    "list item with several paragraphs",
+			storage: `<ul><li><p>a</p><p>b</p></li><li>c</li></ul>`,
+			want:    "- a\n\n  b\n- c",
+		},
+		{
+			name:    "table escapes pipes in cells",
+			storage: `<table><tbody><tr><th>h1</th><th>h2</th></tr><tr><td>a|b</td><td><strong>x</strong></td></tr></tbody></table>`,
+			want:    "| h1 | h2 |\n| --- | --- |\n| a\\|b | **x** |",
+		},
+		{
+			name:    "code macro keeps blank lines",
+			storage: `<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter><ac:plain-text-body><![CDATA[a` + "\n\n\n" + `b]]></ac:plain-text-body></ac:structured-macro>`,
+			want:    "```go\na\n\n\nb\n```",
+		},
+		{
+			name:    "code macro containing a fence",
+			storage: "<ac:structured-macro ac:name=\"code\"><ac:plain-text-body><![CDATA[x ``` y]]></ac:plain-text-body></ac:structured-macro>",
+			want:    "````\nx ``` y\n````",
+		},
+		{
+			name:    "inline code containing backticks",
+			storage: "<p>use <code>a`b</code> and <code>`x</code></p>",
+			want:    "use ``a`b`` and `` `x ``",
+		},
+		{
+			name:    "info panel becomes note callout",
+			storage: `<ac:structured-macro ac:name="info"><ac:rich-text-body><p>note <em>this</em></p></ac:rich-text-body></ac:structured-macro>`,
+			want:    "> [!NOTE]\n> note *this*",
+		},
+		{
+			name:    "warning panel with title",
+			storage: `<ac:structured-macro ac:name="warning"><ac:parameter ac:name="title">Careful</ac:parameter><ac:rich-text-body><p>hot</p></ac:rich-text-body></ac:structured-macro>`,
+			want:    "> [!WARNING]\n> **Careful**\n>\n> hot",
+		},
+		{
+			name: "nested task list as sibling",
+			storage: `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>done</ac:task-body></ac:task>` +
+				`<ac:task-list><ac:task><ac:task-id>2</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>sub</ac:task-body></ac:task></ac:task-list></ac:task-list>`,
+			want: "- [x] done\n  - [ ] sub",
+		},
+		{
+			name: "nested task list inside task body",
+			storage: `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>a` +
+				`<ac:task-list><ac:task><ac:task-id>2</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>b</ac:task-body></ac:task></ac:task-list></ac:task-body></ac:task></ac:task-list>`,
+			want: "- [ ] a\n  - [x] b",
+		},
+		{
+			name:    "links",
+			storage: `<p><a href="https://e.com">site</a> and <a href="https://example.com/a b">spaced</a></p>`,
+			want:    "[site](https://e.com) and [spaced](<https://example.com/a b>)",
+		},
+		{
+			name:    "external link macro",
+			storage: `<p><ac:link><ri:url ri:value="https://e.com/x"/><ac:plain-text-link-body><![CDATA[see]]></ac:plain-text-link-body></ac:link></p>`,
+			want:    "[see](https://e.com/x)",
+		},
+		{
+			name:    "escapes inline markers",
+			storage: `<p>*not em* _x_ [y] a&lt;b&gt;c</p>`,
+			want:    "\\*not em\\* \\_x\\_ \\[y\\] a\\<b>c",
+		},
+		{
+			name:    "escapes block markers at line start",
+			storage: `<p># heading-like</p><p>1. listy</p><p>- dash</p><p>&gt; quote</p>`,
+			want:    "\\# heading-like\n\n1\\. listy\n\n\\- dash\n\n\\> quote",
+		},
+	}
+
+	converter := NewConfluenceClientWithCredentials("https://example.com", "user", "token").newMarkdownConverter(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := converter.convert(tt.storage); got != tt.want {
+				t.Errorf("convert()\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
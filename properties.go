@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PageProperties 页面属性（details宏）中的键值数据
+type PageProperties struct {
+	PageID     string            `json:"page_id"`
+	Title      string            `json:"title"`
+	WebURL     string            `json:"web_url"`
+	Properties map[string]string `json:"properties"`
+}
+
+// extractProperties 提取节点树中 details 宏表格的键值对，多个宏的结果合并
+// detailsID 不为空时只处理 id 参数与之相同的宏；值为单元格转换后的Markdown文本
+func (m *markdownConverter) extractProperties(root *storageNode, detailsID string) map[string]string {
+	properties := make(map[string]string)
+	for _, macro := range findElements(root, "ac:structured-macro") {
+		if macro.macroName() != "details" {
+			continue
+		}
+		if detailsID != "" && macro.macroParams()["id"] != detailsID {
+			continue
+		}
+		body := macro.child("ac:rich-text-body")
+		if body == nil {
+			continue
+		}
+		for _, table := range findElements(body, "table") {
+			m.tableProperties(table, properties)
+		}
+	}
+	return properties
+}
+
+// tableProperties 读取属性表格
+// 纵向表格每行为“键 | 值”；横向表格首行为键、第二行为值
+func (m *markdownConverter) tableProperties(table *storageNode, properties map[string]string) {
+	rows := tableRows(table)
+	if len(rows) == 0 {
+		return
+	}
+
+	if len(rows) == 2 && isHeaderRow(rows[0]) && !isHeaderRow(rows[1]) {
+		for i, cell := range rows[0] {
+			if i < len(rows[1]) {
+				m.setProperty(properties, cell.node, rows[1][i].node)
+			}
+		}
+		return
+	}
+
+	for _, row := range rows {
+		if len(row) >= 2 {
+			m.setProperty(properties, row[0].node, row[1].node)
+		}
+	}
+}
+
+// setProperty 以键单元格的纯文本为键、值单元格的Markdown为值写入属性
+func (m *markdownConverter) setProperty(properties map[string]string, keyCell, valueCell *storageNode) {
+	key := strings.TrimSpace(whitespaceRe.ReplaceAllString(strings.ReplaceAll(keyCell.textContent(), "\u00a0", " "), " "))
+	if key == "" {
+		return
+	}
+	properties[key] = m.renderCellInline(valueCell)
+}
+
+// GetPageProperties 获取单个页面或CQL搜索结果中所有页面的页面属性
+func (c *ConfluenceClient) GetPageProperties(ctx context.Context, pageID, cql, detailsID string, limit int) ([]PageProperties, error) {
+	var pages []PageResponse
+	switch {
+	case pageID != "":
+		page, err := c.GetPageContent(ctx, pageID)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, *page)
+	case cql != "":
+		searchResp, err := c.SearchContentByCQL(ctx, cql, limit, 0)
+		if err != nil {
+			return nil, err
+		}
+		pages = searchResp.Results
+	default:
+		return nil, fmt.Errorf("需要提供 page_id 或 cql")
+	}
+
+	converter := c.newMarkdownConverter(ctx)
+	result := []PageProperties{}
+	for i, page := range pages {
+		converter.setPage(&pages[i])
+		properties := converter.extractProperties(parseStorage(page.Body.Storage.Value), detailsID)
+		// 查询多个页面时与 detailssummary 报表一致，跳过没有页面属性的页面
+		if len(properties) == 0 && pageID == "" {
+			continue
+		}
+		result = append(result, PageProperties{
+			PageID:     page.ID,
+			Title:      page.Title,
+			WebURL:     c.BaseURL + page.Links.Webui,
+			Properties: properties,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy 请求重试策略
+type RetryPolicy struct {
+	MaxRetries int           // 最大重试次数，0 表示不重试
+	BaseDelay  time.Duration // 指数退避的基础等待时间
+	MaxDelay   time.Duration // 指数退避的单次等待上限（服务端通过 Retry-After 指定的等待不受此限制）
+	MaxElapsed time.Duration // 包括所有重试和等待在内的总时间预算
+}
+
+// DefaultRetryPolicy 默认重试策略，可通过环境变量覆盖：
+// CONFLUENCE_MAX_RETRIES（重试次数）、CONFLUENCE_RETRY_MAX_ELAPSED（总时间预算，如 "60s"）
+func DefaultRetryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+		MaxElapsed: 60 * time.Second,
+	}
+	if v, err := strconv.Atoi(os.Getenv("CONFLUENCE_MAX_RETRIES")); err == nil && v >= 0 {
+		policy.MaxRetries = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("CONFLUENCE_RETRY_MAX_ELAPSED")); err == nil && v > 0 {
+		policy.MaxElapsed = v
+	}
+	return policy
+}
+
+// isIdempotentRequest 判断请求重复发送是否安全
+// 页面更新（PUT）会递增版本号：若首次请求已生效但返回网关错误，重试会得到 409 并被误报为版本冲突，
+// 因此修改类请求只在限流（429，请求未被处理）时重试；
+// 内容格式转换虽然使用 POST，但不会修改任何内容，也可以安全重试
+func isIdempotentRequest(method, endpoint string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return strings.HasPrefix(endpoint, "/contentbody/convert/")
+	}
+	return false
+}
+
+// do 按重试策略发送请求，newRequest 每次尝试都会被调用以生成新的请求（请求体只能读取一次）
+// 所有尝试共用一个截止时间为 MaxElapsed 的上下文，单次请求耗时过长也不会超出总时间预算；
+// 该上下文在响应体关闭时才释放，因此调用方必须关闭返回的响应体
+// 返回最后一次尝试的响应或错误，状态码的处理由调用方负责
+func (p RetryPolicy) do(ctx context.Context, client *http.Client, idempotent bool, newRequest func(context.Context) (*http.Request, error)) (*http.Response, error) {
+	start := time.Now()
+	cancel := context.CancelFunc(func() {})
+	if p.MaxElapsed > 0 {
+		ctx, cancel = context.WithDeadline(ctx, start.Add(p.MaxElapsed))
+	}
+
+	resp, err := p.retry(ctx, start, client, idempotent, newRequest)
+	if resp == nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, err
+}
+
+// cancelOnClose 在响应体关闭时释放请求使用的上下文
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retry 执行请求和重试，ctx 已包含总时间预算的截止时间
+func (p RetryPolicy) retry(ctx context.Context, start time.Time, client *http.Client, idempotent bool, newRequest func(context.Context) (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+
+		// 限流（429）时请求未被处理，任何方法都可以重试；网关错误和网络错误只对幂等请求重试
+		retryable := false
+		var delay time.Duration
+		reason := ""
+		if err != nil {
+			retryable = idempotent && ctx.Err() == nil
+			reason = err.Error()
+		} else {
+			switch resp.StatusCode {
+			case http.StatusTooManyRequests:
+				retryable = true
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retryable = idempotent
+			}
+			delay = retryAfter(resp.Header, time.Now())
+			reason = resp.Status
+		}
+
+		if !retryable {
+			if attempt > 0 {
+				log.Printf("%s %s 重试 %d 次后结束: %s", req.Method, req.URL.Path, attempt, reason)
+			}
+			return resp, err
+		}
+		if attempt >= p.MaxRetries {
+			log.Printf("%s %s 重试 %d 次后仍失败: %s", req.Method, req.URL.Path, attempt, reason)
+			return resp, err
+		}
+
+		if delay == 0 {
+			delay = p.backoff(attempt)
+		}
+		if time.Since(start)+delay > p.MaxElapsed {
+			log.Printf("%s %s 超出重试时间预算（已重试 %d 次，需再等待 %v）: %s", req.Method, req.URL.Path, attempt, delay, reason)
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		log.Printf("%s %s 第 %d/%d 次重试，等待 %v: %s", req.Method, req.URL.Path, attempt+1, p.MaxRetries, delay.Round(time.Millisecond), reason)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff 计算第 attempt 次重试的指数退避等待时间，在 [d/2, d] 内随机抖动以避免请求同时重发
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter 从响应头解析服务端要求的等待时间，未指定时返回 0
+// 优先使用 Retry-After（秒数或HTTP日期），其次使用 Confluence Cloud 的 X-RateLimit-Reset（ISO 8601 时间或Unix时间戳）
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if v := strings.TrimSpace(header.Get("Retry-After")); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	if v := strings.TrimSpace(header.Get("X-RateLimit-Reset")); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+		if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+			if t := time.Unix(unix, 0); t.After(now) {
+				return t.Sub(now)
+			}
+		}
+	}
+	return 0
+}
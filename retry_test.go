@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newRetryTestClient 创建指向测试服务器、等待时间很短的客户端
+func newRetryTestClient(t *testing.T, handler http.HandlerFunc) *ConfluenceClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewConfluenceClientWithCredentials(server.URL, "user@example.com", "token")
+	client.Retry = RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   5 * time.Millisecond,
+		MaxElapsed: time.Second,
+	}
+	return client
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int // 按顺序返回的状态码，用完后返回 200
+		wantCalls  int
+		wantStatus int // 0 表示期望成功
+	}{
+		{"GET retried after rate limit", http.MethodGet, []int{429, 429}, 3, 0},
+		{"GET retried after gateway error", http.MethodGet, []int{503}, 2, 0},
+		{"GET gives up after max retries", http.MethodGet, []int{503, 503, 503, 503, 503}, 4, 503},
+		{"GET not retried on not found", http.MethodGet, []int{404}, 1, 404},
+		{"PUT retried after rate limit", http.MethodPut, []int{429}, 2, 0},
+		{"PUT not retried after gateway timeout", http.MethodPut, []int{504}, 1, 504},
+		{"POST not retried after gateway error", http.MethodPost, []int{502}, 1, 502},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= len(tt.statuses) {
+					w.WriteHeader(tt.statuses[calls-1])
+					return
+				}
+				w.Write([]byte(`{}`))
+			})
+
+			var body interface{}
+			if tt.method != http.MethodGet {
+				body = map[string]string{"id": "1"}
+			}
+			resp, err := client.makeRequest(context.Background(), tt.method, "/content/1", body)
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("server received %d requests, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("makeRequest() error = %v", err)
+				}
+				return
+			}
+			apiErr, ok := err.(*APIError)
+			if !ok || apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("makeRequest() error = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRetryBudget(t *testing.T) {
+	calls := 0
+	client := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := client.makeRequest(context.Background(), http.MethodGet, "/content/1", nil)
+	if calls != 1 {
+		t.Errorf("server received %d requests, want 1 when Retry-After exceeds the budget", calls)
+	}
+	if apiErr, ok := err.(*APIError); !ok || apiErr.RetryAfter != 120*time.Second {
+		t.Errorf("makeRequest() error = %v, want rate limited error with RetryAfter 120s", err)
+	}
+}
+
+func TestMakeRequestRetryBudgetCapsSlowAttempt(t *testing.T) {
+	client := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	client.Retry.MaxElapsed = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.makeRequest(context.Background(), http.MethodGet, "/content/1", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("makeRequest() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("makeRequest() took %v, want it to stop at the retry budget", elapsed)
+	}
+}
+
+func TestMakeRequestBodyReadableAfterReturn(t *testing.T) {
+	client := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1"}`))
+	})
+
+	resp, err := client.makeRequest(context.Background(), http.MethodGet, "/content/1", nil)
+	if err != nil {
+		t.Fatalf("makeRequest() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != `{"id":"1"}` {
+		t.Errorf("reading body = %q, %v", body, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		{"http date", http.Header{"Retry-After": {now.Add(30 * time.Second).Format(http.TimeFormat)}}, 30 * time.Second},
+		{"rate limit reset ISO 8601", http.Header{"X-Ratelimit-Reset": {now.Add(time.Minute).Format(time.RFC3339)}}, time.Minute},
+		{"rate limit reset unix", http.Header{"X-Ratelimit-Reset": {"1704067210"}}, 10 * time.Second},
+		{"reset in the past", http.Header{"X-Ratelimit-Reset": {now.Add(-time.Minute).Format(time.RFC3339)}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header, now); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffWithinBounds(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		limit := min(policy.BaseDelay<<attempt, policy.MaxDelay)
+		if got := policy.backoff(attempt); got < limit/2 || got > limit {
+			t.Errorf("backoff(%d) = %v, want within [%v, %v]", attempt, got, limit/2, limit)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// storageSection 存储格式中标题对应的章节范围（字节偏移）
+type storageSection struct {
+	HeadingStart int64 // 标题元素起始位置
+	BodyStart    int64 // 标题元素结束位置，即章节正文起始位置
+	End          int64 // 章节结束位置：下一个同级或更高级标题的起始位置，或所在容器的内容末尾
+}
+
+// headingLevel 返回标题级别，非标题元素返回0
+func headingLevel(n *storageNode) int {
+	if n.Type != elementNode || len(n.Name) != 2 || n.Name[0] != 'h' || n.Name[1] < '1' || n.Name[1] > '6' {
+		return 0
+	}
+	return int(n.Name[1] - '0')
+}
+
+// headingText 返回标题的纯文本，用于匹配
+func headingText(n *storageNode) string {
+	text := strings.ReplaceAll(n.textContent(), "\u00a0", " ")
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(text, " "))
+}
+
+// sectionOf 计算标题所在章节的范围
+// 章节在同一容器内延伸到下一个级别不低于该标题的兄弟标题（或包含这样标题的兄弟元素，如布局），
+// 布局单元格等容器的边界即章节边界
+func sectionOf(heading *storageNode) storageSection {
+	section := storageSection{HeadingStart: heading.Start, BodyStart: heading.End}
+	parent := heading.Parent
+	level := headingLevel(heading)
+
+	found := false
+	for _, sibling := range parent.Children {
+		if sibling == heading {
+			found = true
+			continue
+		}
+		if found && containsHeading(sibling, level) {
+			section.End = sibling.Start
+			return section
+		}
+	}
+
+	// 最后一个子节点的结束位置即容器结束标签的起始位置
+	section.End = parent.Children[len(parent.Children)-1].End
+	return section
+}
+
+// containsHeading 判断节点本身或其后代是否为级别不低于 level 的标题
+func containsHeading(n *storageNode, level int) bool {
+	if l := headingLevel(n); l > 0 && l <= level {
+		return true
+	}
+	for _, c := range n.Children {
+		if containsHeading(c, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// findSection 按标题文本或标题路径（如 "Design > API"）查找章节，匹配忽略大小写
+// 路径中每一级都在上一级章节范围内查找，匹配到多个标题时返回错误并列出各自的完整路径
+func findSection(root *storageNode, path string) (storageSection, error) {
+	var headings []*storageNode
+	for _, n := range findAllElements(root) {
+		if headingLevel(n) > 0 {
+			headings = append(headings, n)
+		}
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, ">") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return storageSection{}, fmt.Errorf("标题路径为空")
+	}
+
+	lo, hi := int64(0), root.End
+	var section storageSection
+	for i, segment := range segments {
+		// 位于另一个匹配章节内部的同名标题只能通过更长的路径（如 "Sub > Sub"）指定
+		var matched []*storageNode
+		for _, h := range headings {
+			if h.Start >= lo && h.End <= hi && strings.EqualFold(headingText(h), segment) &&
+				(len(matched) == 0 || !sectionContains(matched[len(matched)-1], h)) {
+				matched = append(matched, h)
+			}
+		}
+		switch {
+		case len(matched) == 0:
+			return storageSection{}, fmt.Errorf("未找到标题: %s", strings.Join(segments[:i+1], " > "))
+		case len(matched) > 1:
+			paths := make([]string, len(matched))
+			for j, h := range matched {
+				paths[j] = headingPath(headings, h)
+			}
+			return storageSection{}, fmt.Errorf("标题 %s 匹配到 %d 个章节，请使用完整的标题路径指定: %s",
+				strings.Join(segments[:i+1], " > "), len(matched), strings.Join(paths, "; "))
+		}
+		section = sectionOf(matched[0])
+		lo, hi = section.BodyStart, section.End
+	}
+
+	return section, nil
+}
+
+// headingPath 返回标题的完整路径，由所有章节范围包含该标题的上级标题依次组成
+func headingPath(headings []*storageNode, heading *storageNode) string {
+	var parts []string
+	for _, h := range headings {
+		if h == heading {
+			break
+		}
+		if sectionContains(h, heading) {
+			parts = append(parts, headingText(h))
+		}
+	}
+	return strings.Join(append(parts, headingText(heading)), " > ")
+}
+
+// sectionContains 判断节点是否位于标题所在章节的正文范围内
+func sectionContains(heading, n *storageNode) bool {
+	section := sectionOf(heading)
+	return section.BodyStart <= n.Start && n.End <= section.End
+}
+
+// findAllElements 按文档顺序返回所有后代元素
+func findAllElements(n *storageNode) []*storageNode {
+	var result []*storageNode
+	for _, c := range n.Children {
+		if c.Type == elementNode {
+			result = append(result, c)
+			result = append(result, findAllElements(c)...)
+		}
+	}
+	return result
+}
+
+// patchSection 按模式修改章节，其余内容保持原样
+// replace 替换章节正文（保留标题），append 追加到章节末尾，insert_before 插入到标题之前
+func patchSection(storage, path, mode, content string) (string, error) {
+	section, err := findSection(parseStorage(storage), path)
+	if err != nil {
+		return "", err
+	}
+
+	switch mode {
+	case "replace":
+		return storage[:section.BodyStart] + content + storage[section.End:], nil
+	case "append":
+		return storage[:section.End] + content + storage[section.End:], nil
+	case "insert_before":
+		return storage[:section.HeadingStart] + content + storage[section.HeadingStart:], nil
+	default:
+		return "", fmt.Errorf("不支持的修改模式: %s（可选 replace、append、insert_before）", mode)
+	}
+}
+
+// PatchPageSection 修改页面中指定标题下的章节并提交更新，使用与 UpdatePage 相同的版本检查
+func (c *ConfluenceClient) PatchPageSection(ctx context.Context, pageID, path, mode, content string, expectedVersion int, versionMessage string) (*PageResponse, error) {
+	current, err := c.currentPageForUpdate(ctx, pageID, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := patchSection(current.Body.Storage.Value, path, mode, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.updatePageContent(ctx, current, "", body, versionMessage)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatchSection(t *testing.T) {
+	const doc = `<h1>A</h1><p>a</p><h2>Sub</h2><p>s</p><h1>B</h1><h2>Sub</h2><p>t</p>`
+	const layout = `<ac:layout><ac:layout-section ac:type="two_equal">` +
+		`<ac:layout-cell><h2>Left</h2><p>l</p></ac:layout-cell>` +
+		`<ac:layout-cell><h2>Right</h2><p>r</p></ac:layout-cell>` +
+		`</ac:layout-section></ac:layout>`
+	const prefix = `<p>caf&eacute; &amp; 中文 — &#169; &lt;tag&gt;</p><p>line<br/>break<br>x</p>` +
+		`<ac:structured-macro ac:name="code"><ac:plain-text-body><![CDATA[if a < b && c > d { "中" }]]></ac:plain-text-body></ac:structured-macro>`
+
+	tests := []struct {
+		name    string
+		storage string
+		path    string
+		mode    string
+		content string
+		want    string
+	}{
+		{
+			name:    "replace keeps heading and following sections",
+			storage: doc,
+			path:    "B",
+			mode:    "replace",
+			content: "<p>x</p>",
+			want:    `<h1>A</h1><p>a</p><h2>Sub</h2><p>s</p><h1>B</h1><p>x</p>`,
+		},
+		{
+			name:    "replace includes lower-level subsections",
+			storage: doc,
+			path:    "A",
+			mode:    "replace",
+			content: "<p>x</p>",
+			want:    `<h1>A</h1><p>x</p><h1>B</h1><h2>Sub</h2><p>t</p>`,
+		},
+		{
+			name:    "append at end of section",
+			storage: doc,
+			path:    "A",
+			mode:    "append",
+			content: "<p>x</p>",
+			want:    `<h1>A</h1><p>a</p><h2>Sub</h2><p>s</p><p>x</p><h1>B</h1><h2>Sub</h2><p>t</p>`,
+		},
+		{
+			name:    "append to last section",
+			storage: doc,
+			path:    "B",
+			mode:    "append",
+			content: "<p>x</p>",
+			want:    doc + "<p>x</p>",
+		},
+		{
+			name:    "insert before heading",
+			storage: doc,
+			path:    "B",
+			mode:    "insert_before",
+			content: "<hr/>",
+			want:    `<h1>A</h1><p>a</p><h2>Sub</h2><p>s</p><hr/><h1>B</h1><h2>Sub</h2><p>t</p>`,
+		},
+		{
+			name:    "heading path selects nested heading",
+			storage: doc,
+			path:    "B > Sub",
+			mode:    "replace",
+			content: "<p>x</p>",
+			want:    `<h1>A</h1><p>a</p><h2>Sub</h2><p>s</p><h1>B</h1><h2>Sub</h2><p>x</p>`,
+		},
+		{
+			name:    "match ignores case, markup and non-breaking spaces",
+			storage: `<h2><strong>Release</strong>&nbsp;Notes</h2><p>old</p>`,
+			path:    "release notes",
+			mode:    "replace",
+			content: "<p>new</p>",
+			want:    `<h2><strong>Release</strong>&nbsp;Notes</h2><p>new</p>`,
+		},
+		{
+			name:    "section ends at layout cell boundary",
+			storage: layout,
+			path:    "Left",
+			mode:    "replace",
+			content: "<p>x</p>",
+			want:    strings.Replace(layout, "<p>l</p>", "<p>x</p>", 1),
+		},
+		{
+			name:    "append inside layout cell",
+			storage: layout,
+			path:    "Right",
+			mode:    "append",
+			content: "<p>x</p>",
+			want:    strings.Replace(layout, "<p>r</p>", "<p>r</p><p>x</p>", 1),
+		},
+		{
+			name:    "section stops before layout containing same-level heading",
+			storage: `<h2>Intro</h2><p>i</p>` + layout,
+			path:    "Intro",
+			mode:    "replace",
+			content: "<p>x</p>",
+			want:    `<h2>Intro</h2><p>x</p>` + layout,
+		},
+		{
+			name:    "entities, CDATA, void elements and multibyte text before heading",
+			storage: prefix + `<h2>目标</h2><p>旧内容</p>`,
+			path:    "目标",
+			mode:    "replace",
+			content: "<p>新内容</p>",
+			want:    prefix + `<h2>目标</h2><p>新内容</p>`,
+		},
+		{
+			name:    "insert before heading after multibyte text",
+			storage: prefix + `<h2>目标</h2><p>旧内容</p>`,
+			path:    "目标",
+			mode:    "insert_before",
+			content: "<p>前</p>",
+			want:    prefix + `<p>前</p><h2>目标</h2><p>旧内容</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchSection(tt.storage, tt.path, tt.mode, tt.content)
+			if err != nil {
+				t.Fatalf("patchSection() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("patchSection()\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchSectionErrors(t *testing.T) {
+	const doc = `<h1>A</h1><p>a</p><h2>Sub</h2><p>s</p>`
+
+	tests := []struct {
+		name string
+		path string
+		mode string
+	}{
+		{"unknown heading", "Missing", "replace"},
+		{"nested heading outside parent section", "Sub > A", "replace"},
+		{"empty path", " > ", "replace"},
+		{"unknown mode", "A", "prepend"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := patchSection(doc, tt.path, tt.mode, "<p>x</p>"); err == nil {
+				t.Errorf("patchSection(%q, %q) expected error", tt.path, tt.mode)
+			}
+		})
+	}
+}
+
+func TestFindSectionAmbiguous(t *testing.T) {
+	const doc = `<h1>A</h1><h2>Sub</h2><p>s</p><h1>B</h1><h2>Sub</h2><h3>Sub</h3><p>t</p>` +
+		`<h1>C</h1><h2>Dup</h2><h2>Dup</h2>`
+
+	tests := []struct {
+		name      string
+		path      string
+		wantPaths []string
+	}{
+		{"same heading under different parents", "Sub", []string{"A > Sub", "B > Sub"}},
+		{"same heading at same level", "Dup", []string{"C > Dup", "C > Dup"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := findSection(parseStorage(doc), tt.path)
+			if err == nil {
+				t.Fatalf("findSection(%q) expected ambiguity error", tt.path)
+			}
+			if want := strings.Join(tt.wantPaths, "; "); !strings.HasSuffix(err.Error(), ": "+want) {
+				t.Errorf("findSection(%q) error = %q, want paths %q", tt.path, err, want)
+			}
+		})
+	}
+
+	for _, path := range []string{"A > Sub", "B > Sub", "B > Sub > Sub"} {
+		if _, err := findSection(parseStorage(doc), path); err != nil {
+			t.Errorf("findSection(%q) error = %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// storageNodeType 存储格式节点类型
+type storageNodeType int
+
+const (
+	elementNode storageNodeType = iota
+	textNode
+)
+
+// storageNode Confluence存储格式（XHTML）解析后的节点
+type storageNode struct {
+	Type     storageNodeType
+	Name     string // 元素名，带命名空间前缀，如 "p"、"ac:structured-macro"
+	Attrs    map[string]string
+	Text     string // 文本节点内容（包括CDATA）
+	Children []*storageNode
+	Parent   *storageNode
+	Start    int64 // 节点在原始内容中的起始字节偏移
+	End      int64 // 节点在原始内容中的结束字节偏移
+}
+
+// storageAutoClose 允许不闭合的HTML空元素
+// 不能直接使用 xml.HTMLAutoClose：它按本地名匹配，会把 ac:link 当作 HTML 的 link 元素自动闭合
+var storageAutoClose = []string{"br", "hr", "img", "col", "area", "input", "wbr"}
+
+// parseStorage 将Confluence存储格式解析为节点树
+// 存储格式是不带命名空间声明的XHTML片段，这里使用非严格模式解析，
+// 解析出错时返回已解析的部分，并将剩余内容作为文本节点保留
+func parseStorage(content string) *storageNode {
+	root := &storageNode{Type: elementNode, Name: "#root", End: int64(len(content))}
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = storageAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	current := root
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if rest := content[offset:]; strings.TrimSpace(rest) != "" {
+				current.appendChild(&storageNode{Type: textNode, Text: rest, Start: offset, End: int64(len(content))})
+			}
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &storageNode{
+				Type:  elementNode,
+				Name:  qualifiedName(t.Name),
+				Attrs: make(map[string]string, len(t.Attr)),
+				Start: offset,
+			}
+			for _, attr := range t.Attr {
+				node.Attrs[qualifiedName(attr.Name)] = attr.Value
+			}
+			current.appendChild(node)
+			current = node
+		case xml.EndElement:
+			current.End = decoder.InputOffset()
+			if current.Parent != nil {
+				current = current.Parent
+			}
+		case xml.CharData:
+			current.appendChild(&storageNode{Type: textNode, Text: string(t), Start: offset, End: decoder.InputOffset()})
+		}
+	}
+
+	// 未闭合的元素延伸到内容末尾
+	for n := current; n != root && n != nil; n = n.Parent {
+		n.End = int64(len(content))
+	}
+
+	return root
+}
+
+// qualifiedName 将xml.Name还原为带前缀的名称
+func qualifiedName(name xml.Name) string {
+	local := strings.ToLower(name.Local)
+	if name.Space == "" {
+		return local
+	}
+	return strings.ToLower(name.Space) + ":" + local
+}
+
+// appendChild 添加子节点
+func (n *storageNode) appendChild(child *storageNode) {
+	child.Parent = n
+	n.Children = append(n.Children, child)
+}
+
+// attr 获取属性值
+func (n *storageNode) attr(name string) string {
+	return n.Attrs[name]
+}
+
+// child 返回第一个指定名称的子元素
+func (n *storageNode) child(name string) *storageNode {
+	for _, c := range n.Children {
+		if c.Type == elementNode && c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// childrenNamed 返回所有指定名称的子元素
+func (n *storageNode) childrenNamed(name string) []*storageNode {
+	var result []*storageNode
+	for _, c := range n.Children {
+		if c.Type == elementNode && c.Name == name {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// findElements 按文档顺序返回所有指定名称的后代元素
+func findElements(n *storageNode, name string) []*storageNode {
+	var result []*storageNode
+	for _, c := range n.Children {
+		if c.Type != elementNode {
+			continue
+		}
+		if c.Name == name {
+			result = append(result, c)
+		}
+		result = append(result, findElements(c, name)...)
+	}
+	return result
+}
+
+// textContent 返回节点下所有文本的拼接
+func (n *storageNode) textContent() string {
+	if n.Type == textNode {
+		return n.Text
+	}
+	var sb strings.Builder
+	for _, c := range n.Children {
+		sb.WriteString(c.textContent())
+	}
+	return sb.String()
+}
+
+// macroName 返回宏名称（仅对 ac:structured-macro 有效）
+func (n *storageNode) macroName() string {
+	return strings.ToLower(n.attr("ac:name"))
+}
+
+// macroParams 返回宏的参数
+func (n *storageNode) macroParams() map[string]string {
+	params := make(map[string]string)
+	for _, p := range n.childrenNamed("ac:parameter") {
+		params[p.attr("ac:name")] = strings.TrimSpace(p.textContent())
+	}
+	return params
+}
+
+// validateStorage 检查存储格式是否为格式良好的XHTML，在提交前给出比API 400错误更明确的提示
+func validateStorage(content string) error {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + content + "</root>"))
+	decoder.Entity = xml.HTMLEntity
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("存储格式不是有效的XHTML（请检查标签是否闭合、特殊字符是否转义）: %w", err)
+		}
+	}
+}
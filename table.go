@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tableCell 表格单元格
+type tableCell struct {
+	node    *storageNode
+	header  bool
+	colspan string
+	rowspan string
+}
+
+// tableRows 收集表格的所有行，兼容 thead/tbody/tfoot 包裹
+func tableRows(table *storageNode) [][]tableCell {
+	var rows [][]tableCell
+	var collect func(n *storageNode)
+	collect = func(n *storageNode) {
+		for _, c := range n.Children {
+			if c.Type != elementNode {
+				continue
+			}
+			switch c.Name {
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			case "tr":
+				var row []tableCell
+				for _, cell := range c.Children {
+					if cell.Type != elementNode || (cell.Name != "th" && cell.Name != "td") {
+						continue
+					}
+					row = append(row, tableCell{
+						node:    cell,
+						header:  cell.Name == "th" || c.Parent.Name == "thead",
+						colspan: cell.attr("colspan"),
+						rowspan: cell.attr("rowspan"),
+					})
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	collect(table)
+	return rows
+}
+
+// spans 判断单元格是否跨行或跨列
+func (c tableCell) spans() bool {
+	return (c.colspan != "" && c.colspan != "1") || (c.rowspan != "" && c.rowspan != "1")
+}
+
+// renderTable 渲染表格
+// 普通表格输出为GFM管道表格；存在合并单元格时回退为HTML表格；
+// 单元格中包含列表、代码块等嵌套块时展开为按行列出的列表
+func (m *markdownConverter) renderTable(n *storageNode) string {
+	rows := tableRows(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	simple := true
+	for _, row := range rows {
+		for _, cell := range row {
+			if cell.spans() {
+				return m.renderHTMLTable(rows)
+			}
+			if !m.isSimpleCell(cell.node) {
+				simple = false
+			}
+		}
+	}
+	if !simple {
+		return m.renderFlattenedTable(rows)
+	}
+	return m.renderPipeTable(rows)
+}
+
+// isSimpleCell 判断单元格是否只包含段落和行内内容
+func (m *markdownConverter) isSimpleCell(n *storageNode) bool {
+	for _, c := range n.Children {
+		if !m.isBlock(c) {
+			continue
+		}
+		if c.Name != "p" && c.Name != "div" && c.Name != "span" {
+			return false
+		}
+		if !m.isSimpleCell(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderCellInline 将单元格内容渲染为单行文本，段落和换行以 <br> 连接
+func (m *markdownConverter) renderCellInline(n *storageNode) string {
+	text := m.renderBlocks(n.Children)
+	text = strings.ReplaceAll(text, "\n\n", "<br>")
+	text = strings.ReplaceAll(text, "  \n", "<br>")
+	text = strings.ReplaceAll(text, "\n", " ")
+	return strings.TrimSpace(text)
+}
+
+// renderPipeTable 渲染GFM管道表格
+func (m *markdownConverter) renderPipeTable(rows [][]tableCell) string {
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	// 首行全部为表头单元格时作为表头，否则使用空表头
+	var header []string
+	body := rows
+	if isHeaderRow(rows[0]) {
+		for _, cell := range rows[0] {
+			header = append(header, m.renderCellInline(cell.node))
+		}
+		body = rows[1:]
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for i := 0; i < columns; i++ {
+			text := ""
+			if i < len(cells) {
+				text = strings.ReplaceAll(cells[i], "|", "\\|")
+			}
+			sb.WriteString(" " + text + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(header)
+	sb.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+	for _, row := range body {
+		var cells []string
+		for _, cell := range row {
+			cells = append(cells, m.renderCellInline(cell.node))
+		}
+		writeRow(cells)
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// renderHTMLTable 渲染包含合并单元格的表格为HTML
+func (m *markdownConverter) renderHTMLTable(rows [][]tableCell) string {
+	var sb strings.Builder
+	sb.WriteString("<table>\n")
+	for _, row := range rows {
+		sb.WriteString("<tr>")
+		for _, cell := range row {
+			tag := "td"
+			if cell.header {
+				tag = "th"
+			}
+			sb.WriteString("<" + tag)
+			if cell.colspan != "" && cell.colspan != "1" {
+				sb.WriteString(fmt.Sprintf(` colspan="%s"`, cell.colspan))
+			}
+			if cell.rowspan != "" && cell.rowspan != "1" {
+				sb.WriteString(fmt.Sprintf(` rowspan="%s"`, cell.rowspan))
+			}
+			sb.WriteString(">" + m.renderCellInline(cell.node) + "</" + tag + ">")
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+// renderFlattenedTable 将包含嵌套块的表格展开为“第N行 / 列名”列表
+func (m *markdownConverter) renderFlattenedTable(rows [][]tableCell) string {
+	var columnNames []string
+	body := rows
+	if isHeaderRow(rows[0]) {
+		for _, cell := range rows[0] {
+			columnNames = append(columnNames, m.renderCellInline(cell.node))
+		}
+		body = rows[1:]
+	}
+
+	var sections []string
+	for i, row := range body {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("**第 %d 行**\n", i+1))
+		for j, cell := range row {
+			name := fmt.Sprintf("列 %d", j+1)
+			if j < len(columnNames) && columnNames[j] != "" {
+				name = columnNames[j]
+			}
+			content := m.renderBlocks(cell.node.Children)
+			if m.isSimpleCell(cell.node) {
+				sb.WriteString(fmt.Sprintf("- **%s**: %s\n", name, strings.ReplaceAll(content, "\n", " ")))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("- **%s**:\n\n%s\n\n", name, indentLines(content, "    ")))
+		}
+		sections = append(sections, strings.TrimRight(sb.String(), "\n"))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+// isHeaderRow 判断一行是否全部由表头单元格组成
+func isHeaderRow(row []tableCell) bool {
+	for _, cell := range row {
+		if !cell.header {
+			return false
+		}
+	}
+	return len(row) > 0
+}
+
+// indentLines 为每个非空行添加缩进
+func indentLines(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TaskInfo 页面中的任务（ac:task）
+type TaskInfo struct {
+	PageID    string   `json:"page_id"`
+	PageTitle string   `json:"page_title"`
+	ID        string   `json:"id"`
+	ParentID  string   `json:"parent_id,omitempty"`
+	Status    string   `json:"status"`
+	Completed bool     `json:"completed"`
+	Text      string   `json:"text"`
+	Assignees []string `json:"assignees,omitempty"`
+	DueDate   string   `json:"due_date,omitempty"`
+}
+
+// renderTaskList 渲染任务列表为GFM复选框列表
+// 嵌套任务列表可能位于 ac:task 内、ac:task-body 内，或作为上一个任务之后的兄弟元素直接位于 ac:task-list 中，
+// 三种形式都缩进渲染在所属任务之下
+func (m *markdownConverter) renderTaskList(n *storageNode) string {
+	var items []string
+	for _, c := range n.Children {
+		if c.Type != elementNode {
+			continue
+		}
+		switch c.Name {
+		case "ac:task":
+			items = append(items, m.renderTask(c))
+		case "ac:task-list":
+			sub := m.renderTaskList(c)
+			if sub == "" {
+				continue
+			}
+			if len(items) == 0 {
+				items = append(items, sub)
+			} else {
+				items[len(items)-1] += "\n" + indentLines(sub, "  ")
+			}
+		}
+	}
+	return strings.Join(items, "\n")
+}
+
+// renderTask 渲染单个任务及其嵌套任务
+func (m *markdownConverter) renderTask(task *storageNode) string {
+	line := "- [ ]"
+	if taskCompleted(task) {
+		line = "- [x]"
+	}
+	if body := task.child("ac:task-body"); body != nil {
+		if text := m.renderTaskBody(body); text != "" {
+			line += " " + text
+		}
+	}
+	for _, nested := range nestedTaskLists(task) {
+		if sub := m.renderTaskList(nested); sub != "" {
+			line += "\n" + indentLines(sub, "  ")
+		}
+	}
+	return line
+}
+
+// nestedTaskLists 返回任务内的嵌套任务列表（ac:task 的直接子元素或 ac:task-body 内的任务列表）
+func nestedTaskLists(task *storageNode) []*storageNode {
+	lists := task.childrenNamed("ac:task-list")
+	if body := task.child("ac:task-body"); body != nil {
+		lists = append(lists, outermostElements(body, "ac:task-list")...)
+	}
+	return lists
+}
+
+// outermostElements 返回指定名称的后代元素，不进入已匹配元素的内部
+func outermostElements(n *storageNode, name string) []*storageNode {
+	var result []*storageNode
+	for _, c := range n.Children {
+		if c.Type != elementNode {
+			continue
+		}
+		if c.Name == name {
+			result = append(result, c)
+			continue
+		}
+		result = append(result, outermostElements(c, name)...)
+	}
+	return result
+}
+
+// renderTaskBody 将任务正文渲染为单行文本，嵌套任务列表单独渲染，不计入正文
+func (m *markdownConverter) renderTaskBody(body *storageNode) string {
+	text := m.renderBlocks(withoutElements(body.Children, "ac:task-list"))
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(text, " "))
+}
+
+// withoutElements 返回去掉指定名称元素后的节点列表
+func withoutElements(nodes []*storageNode, name string) []*storageNode {
+	var result []*storageNode
+	for _, n := range nodes {
+		if n.Type != elementNode || n.Name != name {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// taskCompleted 判断任务是否已完成
+func taskCompleted(task *storageNode) bool {
+	if status := task.child("ac:task-status"); status != nil {
+		return strings.TrimSpace(status.textContent()) == "complete"
+	}
+	return false
+}
+
+// extractTasks 按文档顺序提取节点树中的所有任务，嵌套任务记录父任务ID
+// 任务列表中紧跟在任务之后的 ac:task-list 属于该任务
+func (m *markdownConverter) extractTasks(n *storageNode, parentID string) []TaskInfo {
+	var tasks []TaskInfo
+	previousID := parentID
+	for _, c := range n.Children {
+		if c.Type != elementNode {
+			continue
+		}
+		if c.Name == "ac:task-list" && n.Name == "ac:task-list" {
+			tasks = append(tasks, m.extractTasks(c, previousID)...)
+			continue
+		}
+		if c.Name != "ac:task" {
+			tasks = append(tasks, m.extractTasks(c, parentID)...)
+			continue
+		}
+
+		task := TaskInfo{ParentID: parentID, Status: "incomplete"}
+		if id := c.child("ac:task-id"); id != nil {
+			task.ID = strings.TrimSpace(id.textContent())
+		}
+		if taskCompleted(c) {
+			task.Status = "complete"
+			task.Completed = true
+		}
+		if body := c.child("ac:task-body"); body != nil {
+			task.Text = m.renderTaskBody(body)
+			// 嵌套任务的负责人和截止日期属于子任务
+			own := &storageNode{Type: elementNode, Children: withoutElements(body.Children, "ac:task-list")}
+			for _, user := range findElements(own, "ri:user") {
+				task.Assignees = append(task.Assignees, m.userName(user))
+			}
+			if times := findElements(own, "time"); len(times) > 0 {
+				task.DueDate = times[0].attr("datetime")
+			}
+		}
+		tasks = append(tasks, task)
+		previousID = task.ID
+		for _, nested := range nestedTaskLists(c) {
+			tasks = append(tasks, m.extractTasks(nested, task.ID)...)
+		}
+	}
+	return tasks
+}
+
+// filterTasks 按状态和负责人过滤任务
+func filterTasks(tasks []TaskInfo, status, assignee string) []TaskInfo {
+	var result []TaskInfo
+	assignee = strings.ToLower(strings.TrimPrefix(assignee, "@"))
+	for _, task := range tasks {
+		if status != "" && status != "all" && task.Status != status {
+			continue
+		}
+		if assignee != "" && !containsFold(task.Assignees, assignee) {
+			continue
+		}
+		result = append(result, task)
+	}
+	return result
+}
+
+// containsFold 判断列表中是否有元素包含指定子串（忽略大小写）
+func containsFold(values []string, substr string) bool {
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListTasks 获取单个页面或CQL搜索结果中所有页面的任务
+func (c *ConfluenceClient) ListTasks(ctx context.Context, pageID, cql string, limit int) ([]TaskInfo, error) {
+	var pages []PageResponse
+	switch {
+	case pageID != "":
+		page, err := c.GetPageContent(ctx, pageID)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, *page)
+	case cql != "":
+		searchResp, err := c.SearchContentByCQL(ctx, cql, limit, 0)
+		if err != nil {
+			return nil, err
+		}
+		pages = searchResp.Results
+	default:
+		return nil, fmt.Errorf("需要提供 page_id 或 cql")
+	}
+
+	converter := c.newMarkdownConverter(ctx)
+	tasks := []TaskInfo{}
+	for i, page := range pages {
+		converter.setPage(&pages[i])
+		root := parseStorage(page.Body.Storage.Value)
+		for _, task := range converter.extractTasks(root, "") {
+			task.PageID = page.ID
+			task.PageTitle = page.Title
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks, nil
+}